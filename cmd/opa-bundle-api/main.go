@@ -4,13 +4,19 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"path/filepath"
 
 	"github.com/xenitab/opa-bundle-api/pkg/bundle"
 	"github.com/xenitab/opa-bundle-api/pkg/config"
+	"github.com/xenitab/opa-bundle-api/pkg/definition"
+	"github.com/xenitab/opa-bundle-api/pkg/discovery"
 	"github.com/xenitab/opa-bundle-api/pkg/handler"
 	"github.com/xenitab/opa-bundle-api/pkg/logs"
+	"github.com/xenitab/opa-bundle-api/pkg/policy"
 	"github.com/xenitab/opa-bundle-api/pkg/replay"
 	"github.com/xenitab/opa-bundle-api/pkg/rule"
+	"github.com/xenitab/opa-bundle-api/pkg/status"
+	"github.com/xenitab/opa-bundle-api/pkg/util"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -42,17 +48,52 @@ func main() {
 }
 
 func start(cfg config.Client) error {
-	ruleClient := rule.NewClient()
+	ruleClient, err := newRuleClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	err = seedRules(cfg, ruleClient)
+	if err != nil {
+		return err
+	}
+
+	policyClient, err := newPolicyClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	definitionClient, err := newDefinitionClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	discoveryClient, err := newDiscoveryClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	bundleClient, err := newBundleClient(cfg)
+	if err != nil {
+		return err
+	}
 
-	err := seedRules(ruleClient)
+	logsClient, err := newLogsClient(cfg)
 	if err != nil {
 		return err
 	}
 
-	bundleClient := bundle.NewClient()
-	logsClient := logs.NewClient()
-	replayClient := newReplayClient(ruleClient, bundleClient, logsClient)
-	handlerClient := newHandlerClient(ruleClient, bundleClient, logsClient, replayClient)
+	defer logsClient.Close()
+
+	statusClient := status.NewClient()
+	replayClient := newReplayClient(ruleClient, policyClient, definitionClient, bundleClient, logsClient)
+	jobClient := newJobClient(cfg, replayClient)
+
+	defer jobClient.Close()
+
+	handlerClient := newHandlerClient(ruleClient, policyClient, definitionClient, bundleClient, logsClient, replayClient, jobClient, statusClient, discoveryClient)
+
+	go handlerClient.PrecomputeBundles()
 
 	e := echo.New()
 	e.Use(middleware.Recover())
@@ -64,21 +105,66 @@ func start(cfg config.Client) error {
 	eRules := e.Group("/rules")
 	eRules.GET("", handlerClient.ReadRules)
 	eRules.POST("", handlerClient.CreateRule)
+	eRules.POST("/batch", handlerClient.BatchRules)
+	eRules.GET("/revisions", handlerClient.ReadRevisions)
+	eRules.GET("/revisions/:revision", handlerClient.ReadRevision)
+	eRules.POST("/revisions/:revision/rollback", handlerClient.RollbackRevision)
+	eRules.GET("/diff", handlerClient.DiffRevisions)
+	eRules.GET("/access", handlerClient.ReadAccess)
+	eRules.GET("/analysis", handlerClient.ReadAnalysis)
 	eRules.GET("/:id", handlerClient.ReadRule)
 	eRules.PUT("/:id", handlerClient.UpdateRule)
+	eRules.PATCH("/:id", handlerClient.PatchRule)
 	eRules.DELETE("/:id", handlerClient.DeleteRule)
 
+	ePolicies := e.Group("/policies")
+	ePolicies.GET("", handlerClient.ReadPolicies)
+	ePolicies.POST("", handlerClient.CreatePolicy)
+	ePolicies.GET("/:name", handlerClient.ReadPolicy)
+	ePolicies.PUT("/:name", handlerClient.UpdatePolicy)
+	ePolicies.DELETE("/:name", handlerClient.DeletePolicy)
+
+	eBundles := e.Group("/bundles")
+	eBundles.GET("", handlerClient.ReadDefinitions)
+	eBundles.POST("", handlerClient.CreateDefinition)
+	eBundles.GET("/:name", handlerClient.ReadDefinition)
+	eBundles.PUT("/:name", handlerClient.UpdateDefinition)
+	eBundles.DELETE("/:name", handlerClient.DeleteDefinition)
+
 	eLogs := e.Group("/logs")
 	eLogs.POST("", handlerClient.CreateLogs, middleware.Decompress())
 	eLogs.GET("", handlerClient.ReadLogs)
 	eLogs.GET("/:decisionID", handlerClient.ReadLog)
 
+	eDiscovery := e.Group("/discovery")
+	eDiscovery.GET("", handlerClient.ReadDiscoveryConfig)
+	eDiscovery.PUT("", handlerClient.UpdateDiscoveryConfig)
+	eDiscovery.DELETE("", handlerClient.DeleteDiscoveryConfig)
+	eDiscovery.GET("/discovery.tar.gz", handlerClient.GetDiscoveryBundle)
+
+	e.POST("/status", handlerClient.CreateStatus, middleware.Decompress())
+
+	eAgents := e.Group("/agents")
+	eAgents.GET("", handlerClient.ReadAgents)
+	eAgents.GET("/:id", handlerClient.ReadAgent)
+
+	eEvaluate := e.Group("/evaluate")
+	eEvaluate.POST("", handlerClient.Evaluate)
+	eEvaluate.POST("/batch", handlerClient.EvaluateBatch)
+
 	eReplay := e.Group("/replay")
+	eReplay.POST("", handlerClient.ReplayLogsWithNewRules)
+	eReplay.GET("/jobs", handlerClient.ReadReplayJobs)
+	eReplay.POST("/jobs", handlerClient.CreateReplayJob)
+	eReplay.GET("/jobs/:id", handlerClient.ReadReplayJob)
+	eReplay.GET("/jobs/:id/results", handlerClient.ReadReplayJobResults)
+	eReplay.DELETE("/jobs/:id", handlerClient.DeleteReplayJob)
 	eReplay.GET("/:decisionID", handlerClient.ReplayLogWithCurrentRules)
 	eReplay.POST("/:decisionID", handlerClient.ReplayLogWithNewRules)
 
 	eBundle := e.Group("/bundle")
 	eBundle.GET("/bundle.tar.gz", handlerClient.GetBundle)
+	eBundle.GET("/:file", handlerClient.GetNamedBundle)
 
 	address := net.JoinHostPort(cfg.Address, fmt.Sprintf("%d", cfg.Port))
 	e.Logger.Fatal(e.Start(address))
@@ -96,118 +182,144 @@ func newConfigClient() (config.Client, error) {
 	return config.NewClient(opts)
 }
 
-func newReplayClient(ruleClient *rule.Client, bundleClient *bundle.Client, logsClient *logs.Client) *replay.Client {
+func newRuleClient(cfg config.Client) (*rule.Client, error) {
+	opts := rule.ClientOptions{
+		Store:         util.NewMemoryStore(),
+		RevisionLog:   util.NewMemoryLog(),
+		RevisionLimit: cfg.RuleRevisionLimit,
+		Validation: rule.Validation{
+			Countries:   cfg.AllowedCountries,
+			Cities:      cfg.AllowedCities,
+			Buildings:   cfg.AllowedBuildings,
+			Roles:       cfg.AllowedRoles,
+			DeviceTypes: cfg.AllowedDeviceTypes,
+		},
+	}
+
+	if cfg.Storage == config.StorageFile {
+		opts.Store = util.NewFileStore(filepath.Join(cfg.StorageDirectory, "rules.json"))
+		opts.RevisionLog = util.NewFileLog(filepath.Join(cfg.StorageDirectory, "revisions.jsonl"))
+	}
+
+	return rule.NewClientWithOptions(opts)
+}
+
+func newPolicyClient(cfg config.Client) (*policy.Client, error) {
+	opts := policy.Options{
+		Store:         util.NewMemoryStore(),
+		IncludeStatic: cfg.StaticPolicy,
+	}
+
+	if cfg.Storage == config.StorageFile {
+		opts.Store = util.NewFileStore(filepath.Join(cfg.StorageDirectory, "policies.json"))
+	}
+
+	return policy.NewClient(opts)
+}
+
+func newDefinitionClient(cfg config.Client) (*definition.Client, error) {
+	opts := definition.Options{
+		Store: util.NewMemoryStore(),
+	}
+
+	if cfg.Storage == config.StorageFile {
+		opts.Store = util.NewFileStore(filepath.Join(cfg.StorageDirectory, "definitions.json"))
+	}
+
+	return definition.NewClient(opts)
+}
+
+func newDiscoveryClient(cfg config.Client) (*discovery.Client, error) {
+	opts := discovery.Options{
+		Store:    util.NewMemoryStore(),
+		Service:  cfg.DiscoveryService,
+		Decision: cfg.DiscoveryDecision,
+	}
+
+	if cfg.Storage == config.StorageFile {
+		opts.Store = util.NewFileStore(filepath.Join(cfg.StorageDirectory, "discovery.json"))
+	}
+
+	return discovery.NewClient(opts)
+}
+
+func newBundleClient(cfg config.Client) (*bundle.Client, error) {
+	opts := bundle.Options{
+		SigningKey:       cfg.SigningKey,
+		SigningAlgorithm: cfg.SigningAlgorithm,
+		SigningKeyID:     cfg.SigningKeyID,
+		SigningScope:     cfg.SigningScope,
+		Delta:            cfg.DeltaBundles,
+		CacheSize:        cfg.BundleCacheSize,
+	}
+
+	if cfg.Storage == config.StorageFile {
+		opts.ClaimsFile = filepath.Join(cfg.StorageDirectory, "claims.json")
+	}
+
+	return bundle.NewClientWithOptions(opts)
+}
+
+func newLogsClient(cfg config.Client) (*logs.Client, error) {
+	opts := logs.Options{
+		Store:   logs.NewMemoryStore(),
+		MaxAge:  cfg.LogsMaxAge,
+		MaxSize: cfg.LogsMaxSize,
+	}
+
+	if cfg.Storage == config.StorageFile {
+		opts.Store = logs.NewFileStore(filepath.Join(cfg.StorageDirectory, "logs"))
+	}
+
+	return logs.NewClient(opts)
+}
+
+func newReplayClient(ruleClient *rule.Client, policyClient *policy.Client, definitionClient *definition.Client, bundleClient *bundle.Client, logsClient *logs.Client) *replay.Client {
 	opts := replay.Options{
-		RuleClient:   ruleClient,
-		BundleClient: bundleClient,
-		LogsClient:   logsClient,
+		RuleClient:       ruleClient,
+		BundleClient:     bundleClient,
+		LogsClient:       logsClient,
+		PolicyClient:     policyClient,
+		DefinitionClient: definitionClient,
 	}
 
 	return replay.NewClient(opts)
 }
 
-func newHandlerClient(ruleClient *rule.Client, bundleClient *bundle.Client, logsClient *logs.Client, replayClient *replay.Client) *handler.Client {
-	opts := handler.Options{
-		RuleClient:   ruleClient,
-		BundleClient: bundleClient,
-		LogsClient:   logsClient,
+func newJobClient(cfg config.Client, replayClient *replay.Client) *replay.JobClient {
+	opts := replay.JobOptions{
 		ReplayClient: replayClient,
+		Workers:      cfg.ReplayWorkers,
+	}
+
+	return replay.NewJobClient(opts)
+}
+
+func newHandlerClient(ruleClient *rule.Client, policyClient *policy.Client, definitionClient *definition.Client, bundleClient *bundle.Client, logsClient *logs.Client, replayClient *replay.Client, jobClient *replay.JobClient, statusClient *status.Client, discoveryClient *discovery.Client) *handler.Client {
+	opts := handler.Options{
+		RuleClient:       ruleClient,
+		PolicyClient:     policyClient,
+		DefinitionClient: definitionClient,
+		BundleClient:     bundleClient,
+		LogsClient:       logsClient,
+		ReplayClient:     replayClient,
+		StatusClient:     statusClient,
+		DiscoveryClient:  discoveryClient,
+		JobClient:        jobClient,
 	}
 
 	return handler.NewClient(opts)
 }
 
-func seedRules(ruleClient *rule.Client) error {
-	rules := []rule.Options{
-		{
-			// super_admin should have access to everything
-			Country:    rule.WildcardString,
-			City:       rule.WildcardString,
-			Building:   rule.WildcardString,
-			Role:       "super_admin",
-			DeviceType: rule.WildcardString,
-			Action:     rule.ActionAllow,
-		},
-		{
-			// sweden_admin should have access to everything in Sweden
-			Country:    "Sweden",
-			City:       rule.WildcardString,
-			Building:   rule.WildcardString,
-			Role:       "sweden_admin",
-			DeviceType: rule.WildcardString,
-			Action:     rule.ActionAllow,
-		},
-		{
-			// norway_admin should have access to everything in Norway
-			Country:    "Norway",
-			City:       rule.WildcardString,
-			Building:   rule.WildcardString,
-			Role:       "norway_admin",
-			DeviceType: rule.WildcardString,
-			Action:     rule.ActionAllow,
-		},
-		{
-			// printer_admin should have access to all Printers
-			Country:    rule.WildcardString,
-			City:       rule.WildcardString,
-			Building:   rule.WildcardString,
-			Role:       "printer_admin",
-			DeviceType: "Printer",
-			Action:     rule.ActionAllow,
-		},
-		{
-			// user should have access to all Printers in Branch (Alingsås, Sweden)
-			Country:    "Sweden",
-			City:       "Alingsås",
-			Building:   "Branch",
-			Role:       "user",
-			DeviceType: "Printer",
-			Action:     rule.ActionAllow,
-		},
-		{
-			// sweden_manager should have access to all Printers in Sweden
-			Country:    "Sweden",
-			City:       rule.WildcardString,
-			Building:   rule.WildcardString,
-			Role:       "sweden_manager",
-			DeviceType: "Printer",
-			Action:     rule.ActionAllow,
-		},
-		{
-			// janitor should have access to Alarm in HQ (Gothenburg, Sweden)
-			Country:    "Sweden",
-			City:       "Gothenburg",
-			Building:   "HQ",
-			Role:       "janitor",
-			DeviceType: "Alarm",
-			Action:     rule.ActionAllow,
-		},
-		{
-			// janitor should have access to all Alarms in Alingsås (Sweden)
-			Country:    "Sweden",
-			City:       "Alingsås",
-			Building:   rule.WildcardString,
-			Role:       "janitor",
-			DeviceType: "Alarm",
-			Action:     rule.ActionAllow,
-		},
-		{
-			// guests should be denied everything
-			Country:    rule.WildcardString,
-			City:       rule.WildcardString,
-			Building:   rule.WildcardString,
-			Role:       "guest",
-			DeviceType: rule.WildcardString,
-			Action:     rule.ActionDeny,
-		},
+func seedRules(cfg config.Client, ruleClient *rule.Client) error {
+	if cfg.SeedFile == "" {
+		return nil
 	}
 
-	for _, opt := range rules {
-		_, err := ruleClient.Add(opt)
-		if err != nil {
-			return err
-		}
+	rules, err := rule.LoadSeedFile(cfg.SeedFile)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return ruleClient.Seed(rules, cfg.SeedMode)
 }
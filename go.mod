@@ -3,6 +3,7 @@ module github.com/xenitab/opa-bundle-api
 go 1.16
 
 require (
+	github.com/ghodss/yaml v1.0.0
 	github.com/labstack/echo/v4 v4.3.0
 	github.com/open-policy-agent/opa v0.28.0
 	github.com/urfave/cli/v2 v2.3.0
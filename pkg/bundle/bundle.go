@@ -3,97 +3,172 @@ package bundle
 import (
 	"bytes"
 	"embed"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"io/fs"
 	"os"
+	"path/filepath"
 	"sync"
 
 	opabundle "github.com/open-policy-agent/opa/bundle"
+	"github.com/xenitab/opa-bundle-api/pkg/util"
 )
 
-var NullBundle = opabundle.Bundle{}
+var (
+	NullBundle              = opabundle.Bundle{}
+	DefaultSigningAlgorithm = "RS256"
+	DefaultSigningKeyID     = "opa-bundle-api"
+	DefaultClaimsFile       = filepath.Join(os.TempDir(), "opa-bundle-api-claims.json")
+)
 
 //go:embed static/*
 var content embed.FS
 
+// Options configures the signing and caching of the bundle archives, an empty SigningKey disables signing
+type Options struct {
+	// SigningKey is either a path to a PEM encoded private key or the HMAC secret
+	SigningKey       string
+	SigningAlgorithm string
+	SigningKeyID     string
+	SigningScope     string
+	// ClaimsFile is where the claims of the signatures are written when signing is enabled, since OPA reads them from a file. Defaults to DefaultClaimsFile
+	ClaimsFile string
+	// Delta enables delta bundles for agents that already have an older revision
+	Delta bool
+	// CacheSize is the amount of recently used revisions kept in memory
+	CacheSize int
+}
+
 type Client struct {
 	sync.RWMutex
-	opaBundle        opabundle.Bundle
-	bundled          bool
-	opaBundleArchive []byte
-	archived         bool
-	revision         string
-	archiveRevision  string
+	cache         *cache
+	deltaCache    *cache
+	signingConfig *opabundle.SigningConfig
+	signingKeyID  string
+	delta         bool
 }
 
 func NewClient() *Client {
 	return &Client{
-		bundled:  false,
-		archived: false,
+		cache:      newCache(DefaultCacheSize),
+		deltaCache: newCache(DefaultCacheSize),
 	}
 }
 
-func (c *Client) Get(data []byte, revision string) (opabundle.Bundle, error) {
-	if !c.bundled || c.revision != revision {
-		err := c.generate(data, revision)
-		if err != nil {
-			return NullBundle, err
-		}
+// NewClientWithOptions returns a Client that signs the bundle archives if a signing key is configured
+func NewClientWithOptions(opts Options) (*Client, error) {
+	// deltas have their own cache so that agents with many different old revisions don't push out the snapshots
+	client := &Client{
+		cache:      newCache(opts.CacheSize),
+		deltaCache: newCache(opts.CacheSize),
+		delta:      opts.Delta,
 	}
 
-	return c.opaBundle, nil
-}
+	if opts.SigningKey == "" {
+		return client, nil
+	}
 
-func (c *Client) GetArchive(data []byte, revision string) ([]byte, error) {
-	if !c.archived || c.archiveRevision != revision {
-		err := c.generate(data, revision)
-		if err != nil {
-			return nil, err
-		}
+	algorithm := opts.SigningAlgorithm
+	if algorithm == "" {
+		algorithm = DefaultSigningAlgorithm
+	}
+
+	keyID := opts.SigningKeyID
+	if keyID == "" {
+		keyID = DefaultSigningKeyID
+	}
+
+	claimsPath := opts.ClaimsFile
+	if claimsPath == "" {
+		claimsPath = DefaultClaimsFile
+	}
+
+	err := writeClaimsFile(claimsPath, keyID, opts.SigningScope)
+	if err != nil {
+		return nil, err
 	}
 
-	err := c.generateArchive()
+	signingConfig := opabundle.NewSigningConfig(opts.SigningKey, algorithm, claimsPath)
+
+	// fail at start-up instead of when the first bundle is requested
+	_, err = signingConfig.GetPrivateKey()
 	if err != nil {
 		return nil, err
 	}
 
-	return c.opaBundleArchive, nil
+	client.signingConfig = signingConfig
+	client.signingKeyID = keyID
+
+	return client, nil
 }
 
-func (c *Client) generateArchive() error {
+func (c *Client) Get(bundleContent Content) (opabundle.Bundle, error) {
 	c.Lock()
 	defer c.Unlock()
 
-	if !c.bundled {
-		return errors.New("No bundle created, run Generate() before running GenerateArchive().")
+	entry := c.cache.get(bundleContent.Revision)
+
+	err := generate(entry, bundleContent)
+	if err != nil {
+		return NullBundle, err
 	}
 
-	if c.archived && c.opaBundle.Manifest.Revision == c.archiveRevision {
+	return *entry.bundle, nil
+}
+
+// GetArchive returns the archive for the revision, it is only generated if it isn't one of the recently used revisions
+func (c *Client) GetArchive(bundleContent Content) ([]byte, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	entry := c.cache.get(bundleContent.Revision)
+
+	err := generate(entry, bundleContent)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.generateArchive(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	return entry.archive, nil
+}
+
+func (c *Client) generateArchive(entry *cacheEntry) error {
+	if entry.archive != nil {
 		return nil
 	}
 
+	if entry.bundle == nil {
+		return errors.New("No bundle created, run generate() before running generateArchive().")
+	}
+
+	b := *entry.bundle
+
+	if c.signingConfig != nil {
+		err := b.GenerateSignature(c.signingConfig, c.signingKeyID, true)
+		if err != nil {
+			return err
+		}
+	}
+
 	var buf bytes.Buffer
 	writer := opabundle.NewWriter(&buf).UseModulePath(true)
 
-	err := writer.Write(c.opaBundle)
+	err := writer.Write(b)
 	if err != nil {
 		return err
 	}
 
-	archive := buf.Bytes()
-	c.opaBundleArchive = archive
-	c.archived = true
-	c.archiveRevision = c.opaBundle.Manifest.Revision
+	entry.archive = buf.Bytes()
 
 	return nil
 }
 
-func (c *Client) generate(data []byte, revision string) error {
-	c.Lock()
-	defer c.Unlock()
-
-	if revision == c.revision && c.bundled {
+func generate(entry *cacheEntry, bundleContent Content) error {
+	if entry.bundle != nil {
 		return nil
 	}
 
@@ -104,82 +179,92 @@ func (c *Client) generate(data []byte, revision string) error {
 
 	defer removeDir(tmpDir)
 
-	err = listAndWriteStaticFiles(tmpDir)
+	err = writeModuleFiles(tmpDir, bundleContent.Modules)
+	if err != nil {
+		return err
+	}
+
+	err = writeDataFile(tmpDir, bundleContent.Data)
 	if err != nil {
 		return err
 	}
 
-	err = writeDataFile(tmpDir, data)
+	// the reader verifies that the data and modules are within the roots
+	err = writeManifestFile(tmpDir, bundleContent.Roots)
 	if err != nil {
 		return err
 	}
 
-	b, err := newOpaBundle(tmpDir, revision)
+	b, err := newOpaBundle(tmpDir, bundleContent.Revision)
 	if err != nil {
 		return err
 	}
 
-	c.opaBundle = b
-	c.bundled = true
-	c.revision = revision
+	entry.bundle = &b
 
 	return nil
 }
 
-func writeDataFile(dir string, data []byte) error {
-	dataFilePath := fmt.Sprintf("%s/data.json", dir)
-	return os.WriteFile(dataFilePath, data, 0600)
-}
+// writeClaimsFile writes the claims of the signatures to a file, since OPA only reads the claims (like scope) from a file.
+// The file is overwritten on every start instead of leaving a new one behind
+func writeClaimsFile(claimsPath string, keyID string, scope string) error {
+	claims := map[string]string{
+		"keyid": keyID,
+	}
 
-func removeDir(dir string) {
-	_ = os.RemoveAll(dir)
-}
+	if scope != "" {
+		claims["scope"] = scope
+	}
 
-func listAndWriteStaticFiles(dir string) error {
-	entries, err := content.ReadDir("static")
+	data, err := json.Marshal(claims)
 	if err != nil {
 		return err
 	}
 
-	for _, entry := range entries {
-		err := writeStaticFiles(dir, entry)
-		if err != nil {
-			return err
-		}
-	}
+	return util.WriteFileAtomic(claimsPath, data)
+}
 
-	return nil
+func writeDataFile(dir string, data []byte) error {
+	dataFilePath := fmt.Sprintf("%s/data.json", dir)
+	return os.WriteFile(dataFilePath, data, 0600)
 }
 
-func writeStaticFiles(dir string, entry fs.DirEntry) error {
-	fileInfo, err := entry.Info()
+func writeManifestFile(dir string, roots []string) error {
+	if len(roots) == 0 {
+		return nil
+	}
+
+	manifest := opabundle.Manifest{
+		Roots: &roots,
+	}
+
+	data, err := json.Marshal(&manifest)
 	if err != nil {
 		return err
 	}
 
-	if !fileInfo.IsDir() {
-		fileName := fileInfo.Name()
-		err := writeStaticFile(fileName, dir)
+	manifestFilePath := fmt.Sprintf("%s/.manifest", dir)
+	return os.WriteFile(manifestFilePath, data, 0600)
+}
+
+func removeDir(dir string) {
+	_ = os.RemoveAll(dir)
+}
+
+func writeModuleFiles(dir string, modules []Module) error {
+	for _, module := range modules {
+		filePath := filepath.Join(dir, filepath.FromSlash(module.Path))
+		err := os.MkdirAll(filepath.Dir(filePath), 0700)
 		if err != nil {
 			return err
 		}
-	}
 
-	return nil
-}
-
-func writeStaticFile(fileName string, dir string) error {
-	filePath := fmt.Sprintf("static/%s", fileName)
-	byte, err := content.ReadFile(filePath)
-	if err != nil {
-		return err
+		err = os.WriteFile(filePath, module.Raw, 0600)
+		if err != nil {
+			return err
+		}
 	}
 
-	newFilePath := fmt.Sprintf("%s/%s", dir, fileName)
-	err = os.WriteFile(newFilePath, byte, 0600)
-	if err != nil {
-		return err
-	}
 	return nil
 }
 
@@ -0,0 +1,230 @@
+package bundle
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	opabundle "github.com/open-policy-agent/opa/bundle"
+)
+
+func TestSignedArchive(t *testing.T) {
+	client, err := NewClientWithOptions(Options{
+		SigningKey:       "secret",
+		SigningAlgorithm: "HS256",
+		SigningKeyID:     "test-key",
+		SigningScope:     "write",
+		ClaimsFile:       filepath.Join(t.TempDir(), "claims.json"),
+	})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	bundleContent, err := NewStaticContent([]byte(`{"rules":[]}`))
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	archive, err := client.GetArchive(bundleContent)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	keys := map[string]*opabundle.KeyConfig{
+		"test-key": {
+			Key:       "secret",
+			Algorithm: "HS256",
+		},
+	}
+
+	cases := []struct {
+		scope      string
+		expectFail bool
+	}{
+		{
+			scope:      "write",
+			expectFail: false,
+		},
+		{
+			scope:      "read",
+			expectFail: true,
+		},
+	}
+
+	for _, c := range cases {
+		verificationConfig := opabundle.NewVerificationConfig(keys, "test-key", c.scope, nil)
+		reader := opabundle.NewReader(bytes.NewReader(archive)).WithBundleVerificationConfig(verificationConfig)
+
+		b, err := reader.Read()
+		if err != nil && !c.expectFail {
+			t.Errorf("Expected err to be nil with scope %s: %q", c.scope, err)
+		}
+
+		if err == nil && c.expectFail {
+			t.Errorf("Expected err with scope %s but was nil", c.scope)
+		}
+
+		if err == nil && b.Manifest.Revision != bundleContent.Revision {
+			t.Errorf("Expected revision to be '%s' but was: %s", bundleContent.Revision, b.Manifest.Revision)
+		}
+	}
+}
+
+func TestDiffValues(t *testing.T) {
+	oldData := []byte(`{"rules":[{"id":1,"role":"user"},{"id":2,"role":"guest"},{"id":3,"role":"janitor"}]}`)
+	data := []byte(`{"rules":[{"id":1,"role":"admin"},{"id":4,"role":"guest"}],"extra":true}`)
+
+	var oldValue, value interface{}
+	_ = json.Unmarshal(oldData, &oldValue)
+	_ = json.Unmarshal(data, &value)
+
+	operations := []patchOperation{}
+	diffValues("", oldValue, value, &operations)
+
+	expected := []patchOperation{
+		{Op: patchOpUpsert, Path: "/extra", Value: true},
+		{Op: patchOpReplace, Path: "/rules/0/role", Value: "admin"},
+		{Op: patchOpReplace, Path: "/rules/1/id", Value: float64(4)},
+		{Op: patchOpRemove, Path: "/rules/2"},
+	}
+
+	if !reflect.DeepEqual(operations, expected) {
+		t.Errorf("Expected operations to be %v but was: %v", expected, operations)
+	}
+}
+
+func TestCache(t *testing.T) {
+	c := newCache(2)
+
+	c.get("a").archive = []byte("a")
+	c.get("b").archive = []byte("b")
+	c.get("a")
+	c.get("c")
+
+	if c.get("a").archive == nil {
+		t.Errorf("Expected recently used entry 'a' to be kept")
+	}
+
+	if c.get("b").archive != nil {
+		t.Errorf("Expected least recently used entry 'b' to be evicted")
+	}
+}
+
+func TestDeltaCacheKeepsSnapshots(t *testing.T) {
+	client, err := NewClientWithOptions(Options{
+		Delta:     true,
+		CacheSize: 2,
+	})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	bundleContent, err := NewStaticContent([]byte(`{"rules":[{"id":1}]}`))
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	_, err = client.GetArchive(bundleContent)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		_, err := client.GetDeltaArchive([]byte(`{"rules":[]}`), fmt.Sprintf("old-%d", i), bundleContent)
+		if err != nil {
+			t.Fatalf("Expected err to be nil: %q", err)
+		}
+	}
+
+	_, found := client.cache.entries[bundleContent.Revision]
+	if !found {
+		t.Errorf("Expected the snapshot archive to be kept in the cache")
+	}
+}
+
+func TestClaimsFileOverwritten(t *testing.T) {
+	dir := t.TempDir()
+	claimsFile := filepath.Join(dir, "claims.json")
+
+	for _, scope := range []string{"read", "write"} {
+		_, err := NewClientWithOptions(Options{
+			SigningKey:       "secret",
+			SigningAlgorithm: "HS256",
+			SigningScope:     scope,
+			ClaimsFile:       claimsFile,
+		})
+		if err != nil {
+			t.Fatalf("Expected err to be nil: %q", err)
+		}
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	if len(files) != 1 {
+		t.Errorf("Expected one file in the directory, got: %d", len(files))
+	}
+
+	data, err := os.ReadFile(claimsFile)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	expected := `{"keyid":"opa-bundle-api","scope":"write"}`
+	if string(data) != expected {
+		t.Errorf("Expected claims to be %s, got: %s", expected, data)
+	}
+}
+
+func TestClaimsFileWithoutSigning(t *testing.T) {
+	claimsFile := filepath.Join(t.TempDir(), "claims.json")
+
+	_, err := NewClientWithOptions(Options{
+		ClaimsFile: claimsFile,
+	})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	_, err = os.Stat(claimsFile)
+	if !os.IsNotExist(err) {
+		t.Errorf("Expected no claims file without signing: %v", err)
+	}
+}
+
+func TestDiscoveryContent(t *testing.T) {
+	cases := []struct {
+		decision     string
+		expectedData string
+	}{
+		{
+			decision:     "discovery",
+			expectedData: `{"discovery":{"labels":{"app":"test"}}}`,
+		},
+		{
+			decision:     "config/agents",
+			expectedData: `{"config":{"agents":{"labels":{"app":"test"}}}}`,
+		},
+		{
+			decision:     "config.agents",
+			expectedData: `{"config":{"agents":{"labels":{"app":"test"}}}}`,
+		},
+	}
+
+	for _, c := range cases {
+		bundleContent, err := NewDiscoveryContent([]byte(`{"labels":{"app":"test"}}`), c.decision)
+		if err != nil {
+			t.Fatalf("Expected err to be nil: %q", err)
+		}
+
+		if string(bundleContent.Data) != c.expectedData {
+			t.Errorf("Expected data for %s to be %s, got: %s", c.decision, c.expectedData, bundleContent.Data)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package bundle
+
+import (
+	"container/list"
+
+	opabundle "github.com/open-policy-agent/opa/bundle"
+)
+
+var (
+	DefaultCacheSize = 10
+)
+
+type cacheEntry struct {
+	key     string
+	bundle  *opabundle.Bundle
+	archive []byte
+}
+
+// cache is a least recently used cache of bundles and archives, not safe for concurrent use
+type cache struct {
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+func newCache(size int) *cache {
+	if size <= 0 {
+		size = DefaultCacheSize
+	}
+
+	return &cache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// get returns the entry for the key, creating it if it doesn't exist
+func (c *cache) get(key string) *cacheEntry {
+	element, found := c.entries[key]
+	if found {
+		c.order.MoveToFront(element)
+		return element.Value.(*cacheEntry)
+	}
+
+	entry := &cacheEntry{
+		key: key,
+	}
+
+	c.entries[key] = c.order.PushFront(entry)
+
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+
+	return entry
+}
@@ -0,0 +1,140 @@
+package bundle
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/xenitab/opa-bundle-api/pkg/util"
+)
+
+var (
+	NullContent = Content{}
+	// revisionSeparator separates the data revision from the modules revision
+	revisionSeparator = "."
+)
+
+// Module is a rego file included in the bundle
+type Module struct {
+	Path string
+	Raw  []byte
+}
+
+// Content is everything a bundle is generated from
+type Content struct {
+	Data     []byte
+	Modules  []Module
+	Revision string
+	// Roots are the manifest roots, empty means the bundle owns everything
+	Roots []string
+}
+
+// NewContent returns the content with a revision that is the hash of the data, followed by the hash of the modules if they aren't the static modules
+func NewContent(data []byte, modules []Module) (Content, error) {
+	return NewContentWithRoots(data, modules, nil)
+}
+
+// NewContentWithRoots returns the content with manifest roots, which are part of the modules revision
+func NewContentWithRoots(data []byte, modules []Module, roots []string) (Content, error) {
+	dataRevision, err := util.BytesToHash(data)
+	if err != nil {
+		return NullContent, err
+	}
+
+	modulesRevision, err := hashModules(modules, roots)
+	if err != nil {
+		return NullContent, err
+	}
+
+	staticModules, err := StaticModules()
+	if err != nil {
+		return NullContent, err
+	}
+
+	staticRevision, err := hashModules(staticModules, nil)
+	if err != nil {
+		return NullContent, err
+	}
+
+	revision := dataRevision
+	if modulesRevision != staticRevision {
+		revision = fmt.Sprintf("%s%s%s", dataRevision, revisionSeparator, modulesRevision)
+	}
+
+	return Content{
+		Data:     data,
+		Modules:  modules,
+		Revision: revision,
+		Roots:    roots,
+	}, nil
+}
+
+// NewStaticContent returns the content with only the static modules
+func NewStaticContent(data []byte) (Content, error) {
+	staticModules, err := StaticModules()
+	if err != nil {
+		return NullContent, err
+	}
+
+	return NewContent(data, staticModules)
+}
+
+// SplitRevision returns the data revision (the same as the rule revision) and the modules revision, which is empty for the static modules
+func SplitRevision(revision string) (string, string) {
+	i := strings.Index(revision, revisionSeparator)
+	if i < 0 {
+		return revision, ""
+	}
+
+	return revision[:i], revision[i+len(revisionSeparator):]
+}
+
+// StaticModules returns the modules embedded in the binary
+func StaticModules() ([]Module, error) {
+	entries, err := content.ReadDir("static")
+	if err != nil {
+		return nil, err
+	}
+
+	var modules []Module
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		raw, err := content.ReadFile(fmt.Sprintf("static/%s", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		modules = append(modules, Module{
+			Path: entry.Name(),
+			Raw:  raw,
+		})
+	}
+
+	return modules, nil
+}
+
+func hashModules(modules []Module, roots []string) (string, error) {
+	sorted := append([]Module{}, modules...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Path < sorted[j].Path
+	})
+
+	var buf bytes.Buffer
+	for _, module := range sorted {
+		buf.WriteString(module.Path)
+		buf.WriteByte(0)
+		buf.Write(module.Raw)
+		buf.WriteByte(0)
+	}
+
+	for _, root := range roots {
+		buf.WriteString(root)
+		buf.WriteByte(0)
+	}
+
+	return util.BytesToHash(buf.Bytes())
+}
@@ -0,0 +1,240 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrorDeltaNotEnabled  = errors.New("Delta bundles not enabled")
+	ErrorDeltaNotPossible = errors.New("Not able to express the change as a delta bundle")
+)
+
+const (
+	patchOpUpsert  = "upsert"
+	patchOpRemove  = "remove"
+	patchOpReplace = "replace"
+)
+
+type patchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+type patch struct {
+	Data []patchOperation `json:"data"`
+}
+
+type deltaManifest struct {
+	Revision string   `json:"revision"`
+	Roots    []string `json:"roots"`
+}
+
+// DeltaEnabled returns true if delta bundles can be served, they are not signed so signing disables them
+func (c *Client) DeltaEnabled() bool {
+	return c.delta && c.signingConfig == nil
+}
+
+// GetDeltaArchive returns a delta bundle archive with the patch operations to go from the old revision to the current revision
+func (c *Client) GetDeltaArchive(oldData []byte, oldRevision string, bundleContent Content) ([]byte, error) {
+	if !c.DeltaEnabled() {
+		return nil, ErrorDeltaNotEnabled
+	}
+
+	// delta bundles can only contain data, a change of the modules requires a snapshot
+	_, oldModulesRevision := SplitRevision(oldRevision)
+	_, modulesRevision := SplitRevision(bundleContent.Revision)
+	if oldModulesRevision != modulesRevision {
+		return nil, ErrorDeltaNotPossible
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	entry := c.deltaCache.get(fmt.Sprintf("%s:%s", oldRevision, bundleContent.Revision))
+	if entry.archive != nil {
+		return entry.archive, nil
+	}
+
+	var oldValue interface{}
+	err := json.Unmarshal(oldData, &oldValue)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	err = json.Unmarshal(bundleContent.Data, &value)
+	if err != nil {
+		return nil, err
+	}
+
+	_, oldIsObject := oldValue.(map[string]interface{})
+	_, isObject := value.(map[string]interface{})
+	if !oldIsObject || !isObject {
+		return nil, ErrorDeltaNotPossible
+	}
+
+	operations := []patchOperation{}
+	diffValues("", oldValue, value, &operations)
+
+	roots := bundleContent.Roots
+	if len(roots) == 0 {
+		roots = []string{""}
+	}
+
+	manifest := deltaManifest{
+		Revision: bundleContent.Revision,
+		Roots:    roots,
+	}
+
+	archive, err := writeDeltaArchive(manifest, patch{Data: operations})
+	if err != nil {
+		return nil, err
+	}
+
+	entry.archive = archive
+
+	return archive, nil
+}
+
+// diffValues appends the operations needed to change the old value at path into the new value
+func diffValues(path string, oldValue interface{}, value interface{}, operations *[]patchOperation) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		oldMap, ok := oldValue.(map[string]interface{})
+		if !ok {
+			break
+		}
+
+		for _, key := range sortedKeys(oldMap) {
+			_, found := v[key]
+			if !found {
+				*operations = append(*operations, patchOperation{Op: patchOpRemove, Path: joinPointer(path, key)})
+			}
+		}
+
+		for _, key := range sortedKeys(v) {
+			newValue := v[key]
+			oldKeyValue, found := oldMap[key]
+			if !found {
+				*operations = append(*operations, patchOperation{Op: patchOpUpsert, Path: joinPointer(path, key), Value: newValue})
+				continue
+			}
+
+			diffValues(joinPointer(path, key), oldKeyValue, newValue, operations)
+		}
+
+		return
+	case []interface{}:
+		oldSlice, ok := oldValue.([]interface{})
+		if !ok {
+			break
+		}
+
+		common := len(v)
+		if len(oldSlice) < common {
+			common = len(oldSlice)
+		}
+
+		for i := 0; i < common; i++ {
+			diffValues(joinPointer(path, fmt.Sprintf("%d", i)), oldSlice[i], v[i], operations)
+		}
+
+		for i := common; i < len(v); i++ {
+			*operations = append(*operations, patchOperation{Op: patchOpUpsert, Path: joinPointer(path, "-"), Value: v[i]})
+		}
+
+		// remove from the end so the indexes of the remaining elements don't change
+		for i := len(oldSlice) - 1; i >= common; i-- {
+			*operations = append(*operations, patchOperation{Op: patchOpRemove, Path: joinPointer(path, fmt.Sprintf("%d", i))})
+		}
+
+		return
+	}
+
+	if !reflect.DeepEqual(oldValue, value) {
+		*operations = append(*operations, patchOperation{Op: patchOpReplace, Path: path, Value: value})
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+func joinPointer(path string, key string) string {
+	key = strings.ReplaceAll(key, "~", "~0")
+	key = strings.ReplaceAll(key, "/", "~1")
+	return fmt.Sprintf("%s/%s", path, key)
+}
+
+func writeDeltaArchive(manifest deltaManifest, p patch) ([]byte, error) {
+	manifestBytes, err := json.Marshal(&manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	patchBytes, err := json.Marshal(&p)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buf)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{"/.manifest", manifestBytes},
+		{"/patch.json", patchBytes},
+	}
+
+	for _, file := range files {
+		header := &tar.Header{
+			Name:     file.name,
+			Mode:     0600,
+			Typeflag: tar.TypeReg,
+			Size:     int64(len(file.data)),
+			ModTime:  time.Now(),
+		}
+
+		err := tarWriter.WriteHeader(header)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = tarWriter.Write(file.data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tarWriter.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	err = gzipWriter.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,38 @@
+package bundle
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/xenitab/opa-bundle-api/pkg/util"
+)
+
+// NewDiscoveryContent returns the content of a discovery bundle distributing the agent config at data.<decision>,
+// where decision is the discovery.decision (or discovery.name) of the agents and may be a path separated by / or .
+func NewDiscoveryContent(config json.RawMessage, decision string) (Content, error) {
+	path := strings.FieldsFunc(decision, func(r rune) bool {
+		return r == '/' || r == '.'
+	})
+
+	var value interface{} = config
+	for i := len(path) - 1; i >= 0; i-- {
+		value = map[string]interface{}{
+			path[i]: value,
+		}
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return NullContent, err
+	}
+
+	revision, err := util.BytesToHash(data)
+	if err != nil {
+		return NullContent, err
+	}
+
+	return Content{
+		Data:     data,
+		Revision: revision,
+	}, nil
+}
@@ -1,16 +1,22 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/urfave/cli/v2"
 )
 
 var (
-	NullClient  = Client{}
-	NullOptions = Options{}
+	NullClient           = Client{}
+	NullOptions          = Options{}
+	StorageMemory        = "memory"
+	StorageFile          = "file"
+	ErrorStorageNotValid = errors.New("Storage not valid, use memory or file")
 )
 
 // Options takes the build information and provides the CLI with correct information
@@ -24,15 +30,37 @@ type Options struct {
 
 // Client struct
 type Client struct {
-	Address           string
-	Port              int
-	disableExitOnHelp bool
-	cliReader         io.Reader
-	cliWriter         io.Writer
-	cliErrWriter      io.Writer
-	version           string
-	revision          string
-	created           string
+	Address            string
+	Port               int
+	Storage            string
+	StorageDirectory   string
+	LogsMaxAge         time.Duration
+	LogsMaxSize        int64
+	SeedFile           string
+	SeedMode           string
+	RuleRevisionLimit  int
+	SigningKey         string
+	SigningAlgorithm   string
+	SigningKeyID       string
+	SigningScope       string
+	DeltaBundles       bool
+	BundleCacheSize    int
+	StaticPolicy       bool
+	DiscoveryService   string
+	DiscoveryDecision  string
+	ReplayWorkers      int
+	AllowedCountries   []string
+	AllowedCities      []string
+	AllowedBuildings   []string
+	AllowedRoles       []string
+	AllowedDeviceTypes []string
+	disableExitOnHelp  bool
+	cliReader          io.Reader
+	cliWriter          io.Writer
+	cliErrWriter       io.Writer
+	version            string
+	revision           string
+	created            string
 }
 
 // NewClient returns the Client or error
@@ -91,6 +119,28 @@ func (client *Client) generateMarkdown(filePath string) error {
 func (client *Client) setConfig(cfg Client) {
 	client.Address = cfg.Address
 	client.Port = cfg.Port
+	client.Storage = cfg.Storage
+	client.StorageDirectory = cfg.StorageDirectory
+	client.LogsMaxAge = cfg.LogsMaxAge
+	client.LogsMaxSize = cfg.LogsMaxSize
+	client.SeedFile = cfg.SeedFile
+	client.SeedMode = cfg.SeedMode
+	client.RuleRevisionLimit = cfg.RuleRevisionLimit
+	client.SigningKey = cfg.SigningKey
+	client.SigningAlgorithm = cfg.SigningAlgorithm
+	client.SigningKeyID = cfg.SigningKeyID
+	client.SigningScope = cfg.SigningScope
+	client.DeltaBundles = cfg.DeltaBundles
+	client.BundleCacheSize = cfg.BundleCacheSize
+	client.StaticPolicy = cfg.StaticPolicy
+	client.DiscoveryService = cfg.DiscoveryService
+	client.DiscoveryDecision = cfg.DiscoveryDecision
+	client.ReplayWorkers = cfg.ReplayWorkers
+	client.AllowedCountries = cfg.AllowedCountries
+	client.AllowedCities = cfg.AllowedCities
+	client.AllowedBuildings = cfg.AllowedBuildings
+	client.AllowedRoles = cfg.AllowedRoles
+	client.AllowedDeviceTypes = cfg.AllowedDeviceTypes
 }
 
 func (client *Client) setIO(reader io.Reader, writer io.Writer, errWriter io.Writer) {
@@ -139,13 +189,189 @@ func (client *Client) newCLIFlags() []cli.Flag {
 			EnvVars:  []string{"PORT"},
 			Value:    8080,
 		},
+		&cli.StringFlag{
+			Name:     "storage",
+			Usage:    "The storage backend for persisted state (memory or file)",
+			Required: false,
+			EnvVars:  []string{"STORAGE"},
+			Value:    StorageMemory,
+		},
+		&cli.StringFlag{
+			Name:     "storage-directory",
+			Usage:    "The directory used by the file storage backend",
+			Required: false,
+			EnvVars:  []string{"STORAGE_DIRECTORY"},
+			Value:    "data",
+		},
+		&cli.DurationFlag{
+			Name:     "logs-max-age",
+			Usage:    "How long decision logs are retained, 0 disables the age limit",
+			Required: false,
+			EnvVars:  []string{"LOGS_MAX_AGE"},
+			Value:    7 * 24 * time.Hour,
+		},
+		&cli.Int64Flag{
+			Name:     "logs-max-size",
+			Usage:    "The total size in bytes of retained decision logs, 0 disables the size limit",
+			Required: false,
+			EnvVars:  []string{"LOGS_MAX_SIZE"},
+			Value:    256 * 1024 * 1024,
+		},
+		&cli.StringFlag{
+			Name:     "seed-file",
+			Usage:    "JSON or YAML file with rules loaded at start-up",
+			Required: false,
+			EnvVars:  []string{"SEED_FILE"},
+			Value:    "",
+		},
+		&cli.StringFlag{
+			Name:     "seed-mode",
+			Usage:    "How the seed file is applied: if-empty only seeds an empty store, reconcile makes the store match the file",
+			Required: false,
+			EnvVars:  []string{"SEED_MODE"},
+			Value:    "if-empty",
+		},
+		&cli.IntFlag{
+			Name:     "rule-revision-limit",
+			Usage:    "The amount of rule revisions kept in the history, 0 keeps all of them",
+			Required: false,
+			EnvVars:  []string{"RULE_REVISION_LIMIT"},
+			Value:    100,
+		},
+		&cli.StringFlag{
+			Name:     "signing-key",
+			Usage:    "Path to a PEM encoded private key (RSA/ECDSA) or the HMAC secret used to sign bundles, signing is disabled if empty",
+			Required: false,
+			EnvVars:  []string{"SIGNING_KEY"},
+			Value:    "",
+		},
+		&cli.StringFlag{
+			Name:     "signing-algorithm",
+			Usage:    "The algorithm used to sign bundles, like HS256, RS256 or ES256",
+			Required: false,
+			EnvVars:  []string{"SIGNING_ALGORITHM"},
+			Value:    "RS256",
+		},
+		&cli.StringFlag{
+			Name:     "signing-key-id",
+			Usage:    "The key ID of the signing key, the same as the key name in the OPA keys configuration",
+			Required: false,
+			EnvVars:  []string{"SIGNING_KEY_ID"},
+			Value:    "opa-bundle-api",
+		},
+		&cli.StringFlag{
+			Name:     "signing-scope",
+			Usage:    "The scope added to the signature, verified by OPA if configured",
+			Required: false,
+			EnvVars:  []string{"SIGNING_SCOPE"},
+			Value:    "",
+		},
+		&cli.BoolFlag{
+			Name:     "delta-bundles",
+			Usage:    "Serve delta bundles to agents with a known older revision (requires OPA v0.34.0 or later, not used with signing)",
+			Required: false,
+			EnvVars:  []string{"DELTA_BUNDLES"},
+			Value:    false,
+		},
+		&cli.IntFlag{
+			Name:     "bundle-cache-size",
+			Usage:    "The amount of recently used bundle revisions (and delta bundles) kept in memory",
+			Required: false,
+			EnvVars:  []string{"BUNDLE_CACHE_SIZE"},
+			Value:    10,
+		},
+		&cli.BoolFlag{
+			Name:     "static-policy",
+			Usage:    "Include the static policy in the bundle, disable it to only use the custom policies",
+			Required: false,
+			EnvVars:  []string{"STATIC_POLICY"},
+			Value:    true,
+		},
+		&cli.StringFlag{
+			Name:     "discovery-service",
+			Usage:    "The name of the service in the bootstrap config of the agents using discovery, used to validate the discovery config",
+			Required: false,
+			EnvVars:  []string{"DISCOVERY_SERVICE"},
+			Value:    "api",
+		},
+		&cli.StringFlag{
+			Name:     "discovery-decision",
+			Usage:    "The discovery decision (or name if no decision is set) in the bootstrap config of the agents, the config is served at data.<decision>",
+			Required: false,
+			EnvVars:  []string{"DISCOVERY_DECISION"},
+			Value:    "discovery",
+		},
+		&cli.IntFlag{
+			Name:     "replay-workers",
+			Usage:    "The amount of replay jobs running at the same time",
+			Required: false,
+			EnvVars:  []string{"REPLAY_WORKERS"},
+			Value:    2,
+		},
+		&cli.StringSliceFlag{
+			Name:     "allowed-countries",
+			Usage:    "The countries rules can use, empty allows every country",
+			Required: false,
+			EnvVars:  []string{"ALLOWED_COUNTRIES"},
+		},
+		&cli.StringSliceFlag{
+			Name:     "allowed-cities",
+			Usage:    "The cities rules can use, empty allows every city",
+			Required: false,
+			EnvVars:  []string{"ALLOWED_CITIES"},
+		},
+		&cli.StringSliceFlag{
+			Name:     "allowed-buildings",
+			Usage:    "The buildings rules can use, empty allows every building",
+			Required: false,
+			EnvVars:  []string{"ALLOWED_BUILDINGS"},
+		},
+		&cli.StringSliceFlag{
+			Name:     "allowed-roles",
+			Usage:    "The roles rules can use, empty allows every role",
+			Required: false,
+			EnvVars:  []string{"ALLOWED_ROLES"},
+		},
+		&cli.StringSliceFlag{
+			Name:     "allowed-device-types",
+			Usage:    "The device types rules can use, empty allows every device type",
+			Required: false,
+			EnvVars:  []string{"ALLOWED_DEVICE_TYPES"},
+		},
 	}
 }
 
 func (client *Client) setConfigFromCLI(cli *cli.Context) error {
+	storage := cli.String("storage")
+	if storage != StorageMemory && storage != StorageFile {
+		return ErrorStorageNotValid
+	}
+
 	newCfg := Client{
-		Address: cli.String("address"),
-		Port:    cli.Int("port"),
+		Address:            cli.String("address"),
+		Port:               cli.Int("port"),
+		Storage:            storage,
+		StorageDirectory:   cli.String("storage-directory"),
+		LogsMaxAge:         cli.Duration("logs-max-age"),
+		LogsMaxSize:        cli.Int64("logs-max-size"),
+		SeedFile:           cli.String("seed-file"),
+		SeedMode:           cli.String("seed-mode"),
+		RuleRevisionLimit:  cli.Int("rule-revision-limit"),
+		SigningKey:         cli.String("signing-key"),
+		SigningAlgorithm:   cli.String("signing-algorithm"),
+		SigningKeyID:       cli.String("signing-key-id"),
+		SigningScope:       cli.String("signing-scope"),
+		DeltaBundles:       cli.Bool("delta-bundles"),
+		BundleCacheSize:    cli.Int("bundle-cache-size"),
+		StaticPolicy:       cli.Bool("static-policy"),
+		DiscoveryService:   cli.String("discovery-service"),
+		DiscoveryDecision:  cli.String("discovery-decision"),
+		ReplayWorkers:      cli.Int("replay-workers"),
+		AllowedCountries:   splitValues(cli.StringSlice("allowed-countries")),
+		AllowedCities:      splitValues(cli.StringSlice("allowed-cities")),
+		AllowedBuildings:   splitValues(cli.StringSlice("allowed-buildings")),
+		AllowedRoles:       splitValues(cli.StringSlice("allowed-roles")),
+		AllowedDeviceTypes: splitValues(cli.StringSlice("allowed-device-types")),
 	}
 
 	client.setConfig(newCfg)
@@ -153,6 +379,21 @@ func (client *Client) setConfigFromCLI(cli *cli.Context) error {
 	return nil
 }
 
+// splitValues supports comma separated values in addition to repeating the flag
+func splitValues(values []string) []string {
+	var res []string
+	for _, value := range values {
+		for _, v := range strings.Split(value, ",") {
+			v = strings.TrimSpace(v)
+			if v != "" {
+				res = append(res, v)
+			}
+		}
+	}
+
+	return res
+}
+
 func (client *Client) versionHandler(c *cli.Context) {
 	fmt.Printf("version=%s revision=%s created=%s\n", client.version, client.revision, client.created)
 	os.Exit(0)
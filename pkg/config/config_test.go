@@ -11,6 +11,28 @@ func TestNewConfig(t *testing.T) {
 	envVarsToClear := []string{
 		"ADDRESS",
 		"PORT",
+		"STORAGE",
+		"STORAGE_DIRECTORY",
+		"LOGS_MAX_AGE",
+		"LOGS_MAX_SIZE",
+		"SEED_FILE",
+		"SEED_MODE",
+		"RULE_REVISION_LIMIT",
+		"SIGNING_KEY",
+		"SIGNING_ALGORITHM",
+		"SIGNING_KEY_ID",
+		"SIGNING_SCOPE",
+		"DELTA_BUNDLES",
+		"BUNDLE_CACHE_SIZE",
+		"STATIC_POLICY",
+		"DISCOVERY_SERVICE",
+		"DISCOVERY_DECISION",
+		"REPLAY_WORKERS",
+		"ALLOWED_COUNTRIES",
+		"ALLOWED_CITIES",
+		"ALLOWED_BUILDINGS",
+		"ALLOWED_ROLES",
+		"ALLOWED_DEVICE_TYPES",
 	}
 
 	for _, envVar := range envVarsToClear {
@@ -23,7 +45,7 @@ func TestNewConfig(t *testing.T) {
 	})
 
 	baseArgs := []string{"fake-bin"}
-	baseWorkingArgs := append(baseArgs)
+	baseWorkingArgs := baseArgs
 
 	cases := []struct {
 		client              *Client
@@ -40,6 +62,20 @@ func TestNewConfig(t *testing.T) {
 			outBuffer:           bytes.Buffer{},
 			errBuffer:           bytes.Buffer{},
 		},
+		{
+			client:              cliClient,
+			args:                append(baseArgs, "--storage", "file", "--storage-directory", "/tmp/opa-bundle-api"),
+			expectedErrContains: "",
+			outBuffer:           bytes.Buffer{},
+			errBuffer:           bytes.Buffer{},
+		},
+		{
+			client:              cliClient,
+			args:                append(baseArgs, "--storage", "database"),
+			expectedErrContains: "Storage not valid",
+			outBuffer:           bytes.Buffer{},
+			errBuffer:           bytes.Buffer{},
+		},
 	}
 
 	for _, c := range cases {
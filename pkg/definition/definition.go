@@ -0,0 +1,193 @@
+package definition
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/xenitab/opa-bundle-api/pkg/rule"
+	"github.com/xenitab/opa-bundle-api/pkg/util"
+)
+
+var (
+	NullDefinition         = Definition{}
+	DefaultName            = "bundle"
+	ErrorNameAlreadyExists = errors.New("Name already exists")
+	ErrorNameNotFound      = errors.New("Name not found")
+	ErrorNameNotValid      = errors.New("Name not valid, only letters, digits, - and _ are allowed and bundle is reserved")
+	validName              = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+)
+
+// Definition is a named bundle containing the rules matching the filter
+type Definition struct {
+	Name   string      `json:"name"`
+	Filter rule.Filter `json:"filter"`
+	// Roots are the manifest roots of the bundle, empty means the bundle owns everything
+	Roots []string `json:"roots,omitempty"`
+}
+
+// State is the definitions saved in the store, sorted by name
+type State struct {
+	Definitions []Definition `json:"definitions"`
+}
+
+// Options configures where the definitions are persisted
+type Options struct {
+	Store util.Store
+}
+
+type Client struct {
+	sync.RWMutex
+	definitions map[string]Definition
+	store       util.Store
+	changed     chan struct{}
+}
+
+// NewClient returns a Client with the definitions loaded from the store
+func NewClient(opts Options) (*Client, error) {
+	store := opts.Store
+	if store == nil {
+		store = util.NewMemoryStore()
+	}
+
+	var state State
+	err := store.Load(&state)
+	if err != nil {
+		return nil, err
+	}
+
+	definitions := make(map[string]Definition)
+	for _, definition := range state.Definitions {
+		definitions[definition.Name] = definition
+	}
+
+	return &Client{
+		definitions: definitions,
+		store:       store,
+		changed:     make(chan struct{}),
+	}, nil
+}
+
+func (client *Client) Add(definition Definition) error {
+	client.Lock()
+	defer client.Unlock()
+
+	if !ValidName(definition.Name) {
+		return ErrorNameNotValid
+	}
+
+	_, found := client.definitions[definition.Name]
+	if found {
+		return ErrorNameAlreadyExists
+	}
+
+	definitions := client.copyDefinitions()
+	definitions[definition.Name] = definition
+
+	return client.apply(definitions)
+}
+
+func (client *Client) Get(name string) (Definition, error) {
+	client.RLock()
+	defer client.RUnlock()
+
+	definition, found := client.definitions[name]
+	if !found {
+		return NullDefinition, ErrorNameNotFound
+	}
+
+	return definition, nil
+}
+
+func (client *Client) GetAll() []Definition {
+	client.RLock()
+	defer client.RUnlock()
+
+	return sortedDefinitions(client.definitions)
+}
+
+// Set replaces the filter and roots of the definition
+func (client *Client) Set(name string, definition Definition) error {
+	client.Lock()
+	defer client.Unlock()
+
+	_, found := client.definitions[name]
+	if !found {
+		return ErrorNameNotFound
+	}
+
+	definition.Name = name
+
+	definitions := client.copyDefinitions()
+	definitions[name] = definition
+
+	return client.apply(definitions)
+}
+
+func (client *Client) Delete(name string) error {
+	client.Lock()
+	defer client.Unlock()
+
+	_, found := client.definitions[name]
+	if !found {
+		return ErrorNameNotFound
+	}
+
+	definitions := client.copyDefinitions()
+	delete(definitions, name)
+
+	return client.apply(definitions)
+}
+
+// Changed returns a channel that is closed the next time the definitions change
+func (client *Client) Changed() <-chan struct{} {
+	client.RLock()
+	defer client.RUnlock()
+
+	return client.changed
+}
+
+// ValidName returns false for names that can't be used in the bundle route, like the name of the default bundle
+func ValidName(name string) bool {
+	return validName.MatchString(name) && name != DefaultName
+}
+
+func (client *Client) apply(definitions map[string]Definition) error {
+	err := client.store.Save(&State{Definitions: sortedDefinitions(definitions)})
+	if err != nil {
+		return err
+	}
+
+	client.definitions = definitions
+
+	close(client.changed)
+	client.changed = make(chan struct{})
+
+	return nil
+}
+
+func (client *Client) copyDefinitions() map[string]Definition {
+	definitions := make(map[string]Definition, len(client.definitions))
+	for k, v := range client.definitions {
+		definitions[k] = v
+	}
+
+	return definitions
+}
+
+func sortedDefinitions(definitions map[string]Definition) []Definition {
+	var names []string
+	for k := range definitions {
+		names = append(names, k)
+	}
+
+	sort.Strings(names)
+
+	res := []Definition{}
+	for _, name := range names {
+		res = append(res, definitions[name])
+	}
+
+	return res
+}
@@ -0,0 +1,234 @@
+package discovery
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	opaconfig "github.com/open-policy-agent/opa/config"
+	"github.com/open-policy-agent/opa/keys"
+	"github.com/open-policy-agent/opa/plugins/bundle"
+	"github.com/open-policy-agent/opa/plugins/logs"
+	"github.com/open-policy-agent/opa/plugins/status"
+	"github.com/xenitab/opa-bundle-api/pkg/util"
+)
+
+var (
+	DefaultService            = "api"
+	DefaultDecision           = "discovery"
+	ErrorConfigNotFound       = errors.New("Discovery config not found")
+	ErrorConfigNotValid       = errors.New("Discovery config not valid")
+	ErrorDiscoveryNotAllowed  = errors.New("Discovery config can't configure discovery")
+	ErrorServicesNotValid     = errors.New("Services not valid, expected a list or an object")
+	errorConfigNotValidFormat = "%w: %s: %v"
+)
+
+// State is the discovery config saved in the store, empty until a config has been set
+type State struct {
+	Config json.RawMessage `json:"config,omitempty"`
+}
+
+// Options configures where the config is persisted and which service the agents use for discovery
+type Options struct {
+	Store util.Store
+	// Service is the name of the service in the bootstrap config of the agents, it can be used by the discovered config
+	Service string
+	// Decision is the discovery.decision (or discovery.name) in the bootstrap config of the agents, the config is served at data.<decision>
+	Decision string
+}
+
+type Client struct {
+	sync.RWMutex
+	config   json.RawMessage
+	store    util.Store
+	service  string
+	decision string
+	changed  chan struct{}
+}
+
+// NewClient returns a Client with the config loaded from the store
+func NewClient(opts Options) (*Client, error) {
+	store := opts.Store
+	if store == nil {
+		store = util.NewMemoryStore()
+	}
+
+	service := opts.Service
+	if service == "" {
+		service = DefaultService
+	}
+
+	decision := opts.Decision
+	if decision == "" {
+		decision = DefaultDecision
+	}
+
+	var state State
+	err := store.Load(&state)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		config:   state.Config,
+		store:    store,
+		service:  service,
+		decision: decision,
+		changed:  make(chan struct{}),
+	}, nil
+}
+
+// Get returns the config distributed to the agents
+func (client *Client) Get() (json.RawMessage, error) {
+	client.RLock()
+	defer client.RUnlock()
+
+	if client.config == nil {
+		return nil, ErrorConfigNotFound
+	}
+
+	return client.config, nil
+}
+
+// Decision returns where the agents read the config from the discovery bundle
+func (client *Client) Decision() string {
+	return client.decision
+}
+
+// Set validates and replaces the config distributed to the agents
+func (client *Client) Set(config json.RawMessage) error {
+	client.Lock()
+	defer client.Unlock()
+
+	err := client.validate(config)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	err = json.Compact(&buf, config)
+	if err != nil {
+		return err
+	}
+
+	return client.apply(json.RawMessage(buf.Bytes()))
+}
+
+// Delete removes the config, the discovery bundle isn't served until a new config is set
+func (client *Client) Delete() error {
+	client.Lock()
+	defer client.Unlock()
+
+	if client.config == nil {
+		return ErrorConfigNotFound
+	}
+
+	return client.apply(nil)
+}
+
+// Changed returns a channel that is closed the next time the config changes
+func (client *Client) Changed() <-chan struct{} {
+	client.RLock()
+	defer client.RUnlock()
+
+	return client.changed
+}
+
+func (client *Client) apply(config json.RawMessage) error {
+	err := client.store.Save(&State{Config: config})
+	if err != nil {
+		return err
+	}
+
+	client.config = config
+
+	close(client.changed)
+	client.changed = make(chan struct{})
+
+	return nil
+}
+
+// validate parses the config the same way as the agents, so an invalid config isn't distributed
+func (client *Client) validate(raw json.RawMessage) error {
+	config, err := opaconfig.ParseConfig(raw, "")
+	if err != nil {
+		return fmt.Errorf(errorConfigNotValidFormat, ErrorConfigNotValid, "config", err)
+	}
+
+	if config.Discovery != nil {
+		return ErrorDiscoveryNotAllowed
+	}
+
+	services, err := serviceNames(config.Services)
+	if err != nil {
+		return err
+	}
+
+	services = append(services, client.service)
+
+	keyConfigs, err := keys.ParseKeysConfig(config.Keys)
+	if err != nil {
+		return fmt.Errorf(errorConfigNotValidFormat, ErrorConfigNotValid, "keys", err)
+	}
+
+	var pluginNames []string
+	for name := range config.Plugins {
+		pluginNames = append(pluginNames, name)
+	}
+
+	_, err = bundle.NewConfigBuilder().WithBytes(config.Bundles).WithServices(services).WithKeyConfigs(keyConfigs).Parse()
+	if err != nil {
+		return fmt.Errorf(errorConfigNotValidFormat, ErrorConfigNotValid, "bundles", err)
+	}
+
+	_, err = logs.ParseConfig(config.DecisionLogs, services, pluginNames)
+	if err != nil {
+		return fmt.Errorf(errorConfigNotValidFormat, ErrorConfigNotValid, "decision_logs", err)
+	}
+
+	_, err = status.ParseConfig(config.Status, services, pluginNames)
+	if err != nil {
+		return fmt.Errorf(errorConfigNotValidFormat, ErrorConfigNotValid, "status", err)
+	}
+
+	return nil
+}
+
+// serviceNames returns the names of the services, which OPA accepts both as a list and as an object
+func serviceNames(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	var list []struct {
+		Name string `json:"name"`
+	}
+
+	err := json.Unmarshal(raw, &list)
+	if err == nil {
+		var names []string
+		for _, service := range list {
+			names = append(names, service.Name)
+		}
+
+		return names, nil
+	}
+
+	var object map[string]json.RawMessage
+	err = json.Unmarshal(raw, &object)
+	if err != nil {
+		return nil, ErrorServicesNotValid
+	}
+
+	var names []string
+	for name := range object {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
@@ -0,0 +1,43 @@
+package discovery
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestSet(t *testing.T) {
+	client, err := NewClient(Options{})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	cases := []struct {
+		config      string
+		expectedErr error
+	}{
+		{
+			config:      `{"bundles": {"api": {"service": "api", "resource": "bundle/bundle.tar.gz"}}}`,
+			expectedErr: nil,
+		},
+		{
+			config:      `{"services": {"other": {"url": "http://other"}}, "decision_logs": {"service": "other"}}`,
+			expectedErr: nil,
+		},
+		{
+			config:      `{"bundles": {"api": {"service": "missing"}}}`,
+			expectedErr: ErrorConfigNotValid,
+		},
+		{
+			config:      `{"discovery": {"name": "discovery"}}`,
+			expectedErr: ErrorDiscoveryNotAllowed,
+		},
+	}
+
+	for _, c := range cases {
+		err := client.Set(json.RawMessage(c.config))
+		if !errors.Is(err, c.expectedErr) {
+			t.Errorf("Expected err to be '%v' for %s but was: %v", c.expectedErr, c.config, err)
+		}
+	}
+}
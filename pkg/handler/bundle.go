@@ -1,37 +1,287 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/xenitab/opa-bundle-api/pkg/bundle"
-	"github.com/xenitab/opa-bundle-api/pkg/util"
+	"github.com/xenitab/opa-bundle-api/pkg/definition"
+	"github.com/xenitab/opa-bundle-api/pkg/discovery"
+)
+
+var (
+	// longPollingContentType tells OPA that the server supports long polling
+	longPollingContentType = "application/vnd.openpolicyagent.bundles"
+	maxLongPollingWait     = 5 * time.Minute
+	bundleFileSuffix       = ".tar.gz"
 )
 
 func (client *Client) GetBundle(c echo.Context) error {
-	data, err := client.ruleClient.GetAllJSON()
-	if err != nil {
-		return err
+	return client.serveBundle(c, true, client.currentContent)
+}
+
+// GetNamedBundle serves the bundles defined through /bundles at /bundle/:name.tar.gz
+func (client *Client) GetNamedBundle(c echo.Context) error {
+	file := c.Param("file")
+	if !strings.HasSuffix(file, bundleFileSuffix) {
+		return echo.NewHTTPError(http.StatusNotFound, definition.ErrorNameNotFound.Error())
 	}
 
-	dataBytes := []byte(data)
-	revision, err := util.BytesToHash(dataBytes)
+	name := strings.TrimSuffix(file, bundleFileSuffix)
+
+	return client.serveBundle(c, false, func() (bundle.Content, error) {
+		return client.bundleContent(name)
+	})
+}
+
+// GetDiscoveryBundle serves the discovery bundle with the agent config managed through /discovery
+func (client *Client) GetDiscoveryBundle(c echo.Context) error {
+	return client.serveBundle(c, false, client.discoveryContent)
+}
 
+// serveBundle sends the content if it isn't the revision the agent already has, delta bundles are only possible if the revision is a rule revision
+func (client *Client) serveBundle(c echo.Context, delta bool, content func() (bundle.Content, error)) error {
 	req := c.Request()
 	headers := req.Header
 	headerIfNoneMatch := headers.Get("If-None-Match")
+	wait := preferWait(headers.Get("Prefer"))
+
+	contentType := "application/gzip"
+	if wait > 0 {
+		contentType = longPollingContentType
+		c.Response().Header().Set(echo.HeaderContentType, contentType)
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	for {
+		// get the channels before the content so that a change in between isn't missed
+		rulesChanged := client.ruleClient.Changed()
+		policiesChanged := client.policyClient.Changed()
+		definitionsChanged := client.definitionClient.Changed()
+		discoveryChanged := client.discoveryClient.Changed()
+
+		bundleContent, err := content()
+		if errors.Is(err, definition.ErrorNameNotFound) || errors.Is(err, discovery.ErrorConfigNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if headerIfNoneMatch != bundleContent.Revision {
+			return client.sendBundle(c, contentType, delta, headerIfNoneMatch, bundleContent)
+		}
+
+		if wait <= 0 {
+			return c.NoContent(http.StatusNotModified)
+		}
+
+		select {
+		case <-rulesChanged:
+		case <-policiesChanged:
+		case <-definitionsChanged:
+		case <-discoveryChanged:
+		case <-timer.C:
+			return c.NoContent(http.StatusNotModified)
+		case <-req.Context().Done():
+			return req.Context().Err()
+		}
+	}
+}
+
+// PrecomputeBundles generates the archives every time the rules, policies, definitions or discovery config change, so polling agents don't have to wait for them
+func (client *Client) PrecomputeBundles() {
+	for {
+		rulesChanged := client.ruleClient.Changed()
+		policiesChanged := client.policyClient.Changed()
+		definitionsChanged := client.definitionClient.Changed()
+		discoveryChanged := client.discoveryClient.Changed()
 
-	if headerIfNoneMatch == revision {
-		return c.NoContent(http.StatusNotModified)
+		names := []string{definition.DefaultName}
+		for _, d := range client.definitionClient.GetAll() {
+			names = append(names, d.Name)
+		}
+
+		for _, name := range names {
+			err := client.precomputeBundle(name)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Unable to precompute bundle %s: %q\n", name, err)
+			}
+		}
+
+		err := client.precomputeDiscoveryBundle()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to precompute discovery bundle: %q\n", err)
+		}
+
+		select {
+		case <-rulesChanged:
+		case <-policiesChanged:
+		case <-definitionsChanged:
+		case <-discoveryChanged:
+		}
+	}
+}
+
+func (client *Client) precomputeBundle(name string) error {
+	bundleContent, err := client.bundleContent(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.bundleClient.GetArchive(bundleContent)
+	return err
+}
+
+func (client *Client) precomputeDiscoveryBundle() error {
+	bundleContent, err := client.discoveryContent()
+	if errors.Is(err, discovery.ErrorConfigNotFound) {
+		return nil
 	}
 
-	bundleClient := bundle.NewClient()
-	archive, err := bundleClient.GetArchive(dataBytes, revision)
 	if err != nil {
 		return err
 	}
 
-	c.Response().Header().Set("ETag", revision)
+	_, err = client.bundleClient.GetArchive(bundleContent)
+	return err
+}
+
+// bundleContent returns the content of the default bundle or the named bundle from the current rules and policies
+func (client *Client) bundleContent(name string) (bundle.Content, error) {
+	if name == definition.DefaultName {
+		return client.currentContent()
+	}
+
+	d, err := client.definitionClient.Get(name)
+	if err != nil {
+		return bundle.NullContent, err
+	}
+
+	return client.definitionContent(d)
+}
+
+// currentContent returns the content of the default bundle from the current rules and policies
+func (client *Client) currentContent() (bundle.Content, error) {
+	data, err := client.ruleClient.GetAllJSON()
+	if err != nil {
+		return bundle.NullContent, err
+	}
+
+	modules, err := client.policyClient.GetModules()
+	if err != nil {
+		return bundle.NullContent, err
+	}
+
+	return bundle.NewContent([]byte(data), modules)
+}
+
+// definitionContent returns the content of a named bundle with only the rules matching the filter
+func (client *Client) definitionContent(d definition.Definition) (bundle.Content, error) {
+	data, err := client.ruleClient.GetFilteredJSON(d.Filter)
+	if err != nil {
+		return bundle.NullContent, err
+	}
+
+	modules, err := client.policyClient.GetModules()
+	if err != nil {
+		return bundle.NullContent, err
+	}
+
+	return bundle.NewContentWithRoots([]byte(data), modules, d.Roots)
+}
+
+// discoveryContent returns the content of the discovery bundle from the current discovery config
+func (client *Client) discoveryContent() (bundle.Content, error) {
+	config, err := client.discoveryClient.Get()
+	if err != nil {
+		return bundle.NullContent, err
+	}
+
+	return bundle.NewDiscoveryContent(config, client.discoveryClient.Decision())
+}
+
+func (client *Client) sendBundle(c echo.Context, contentType string, delta bool, oldRevision string, bundleContent bundle.Content) error {
+	var archive []byte
+	var err error
+
+	if delta {
+		archive, err = client.getDeltaArchive(oldRevision, bundleContent)
+		if err != nil {
+			return err
+		}
+	}
+
+	if archive == nil {
+		archive, err = client.bundleClient.GetArchive(bundleContent)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.Response().Header().Set("ETag", bundleContent.Revision)
+
+	return c.Blob(http.StatusOK, contentType, archive)
+}
+
+// getDeltaArchive returns nil if the agent should get a full snapshot, like when the old revision is unknown
+func (client *Client) getDeltaArchive(oldRevision string, bundleContent bundle.Content) ([]byte, error) {
+	if oldRevision == "" || !client.bundleClient.DeltaEnabled() {
+		return nil, nil
+	}
+
+	oldRuleRevision, _ := bundle.SplitRevision(oldRevision)
+	oldData, err := client.ruleClient.GetRevisionJSON(oldRuleRevision)
+	if err != nil {
+		return nil, nil
+	}
+
+	archive, err := client.bundleClient.GetDeltaArchive([]byte(oldData), oldRevision, bundleContent)
+	if errors.Is(err, bundle.ErrorDeltaNotPossible) {
+		return nil, nil
+	}
+
+	return archive, err
+}
+
+// preferWait returns the duration from a header like "Prefer: wait=600", capped to maxLongPollingWait
+func preferWait(header string) time.Duration {
+	for _, preference := range strings.FieldsFunc(header, func(r rune) bool { return r == ',' || r == ';' }) {
+		key, value, found := cut(strings.TrimSpace(preference), "=")
+		if !found || !strings.EqualFold(key, "wait") {
+			continue
+		}
+
+		seconds, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || seconds <= 0 {
+			return 0
+		}
+
+		wait := time.Duration(seconds) * time.Second
+		if wait > maxLongPollingWait {
+			return maxLongPollingWait
+		}
+
+		return wait
+	}
+
+	return 0
+}
+
+func cut(s string, sep string) (string, string, bool) {
+	i := strings.Index(s, sep)
+	if i < 0 {
+		return s, "", false
+	}
 
-	return c.Blob(http.StatusOK, "application/gzip", archive)
+	return s[:i], s[i+len(sep):], true
 }
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/xenitab/opa-bundle-api/pkg/bundle"
+	"github.com/xenitab/opa-bundle-api/pkg/definition"
+)
+
+func (client *Client) ReadDefinitions(c echo.Context) error {
+	definitions := client.definitionClient.GetAll()
+
+	return c.JSON(http.StatusOK, definitions)
+}
+
+func (client *Client) CreateDefinition(c echo.Context) error {
+	d := definition.Definition{}
+
+	if err := c.Bind(&d); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	err := client.validateDefinition(d)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	err = client.definitionClient.Add(d)
+	if err != nil {
+		return definitionError(err)
+	}
+
+	res, err := client.definitionClient.Get(d.Name)
+	if err != nil {
+		return definitionError(err)
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+func (client *Client) ReadDefinition(c echo.Context) error {
+	d, err := client.definitionClient.Get(c.Param("name"))
+	if err != nil {
+		return definitionError(err)
+	}
+
+	return c.JSON(http.StatusOK, d)
+}
+
+func (client *Client) UpdateDefinition(c echo.Context) error {
+	name := c.Param("name")
+	d := definition.Definition{}
+
+	if err := c.Bind(&d); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	err := client.validateDefinition(d)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	err = client.definitionClient.Set(name, d)
+	if err != nil {
+		return definitionError(err)
+	}
+
+	res, err := client.definitionClient.Get(name)
+	if err != nil {
+		return definitionError(err)
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+func (client *Client) DeleteDefinition(c echo.Context) error {
+	err := client.definitionClient.Delete(c.Param("name"))
+	if err != nil {
+		return definitionError(err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// validateDefinition generates the bundle once, which fails if the data or modules are outside of the roots
+func (client *Client) validateDefinition(d definition.Definition) error {
+	bundleContent, err := client.definitionContent(d)
+	if err != nil {
+		return err
+	}
+
+	// the candidate bundle shouldn't push the served bundles out of the cache
+	_, err = bundle.NewClient().Get(bundleContent)
+	return err
+}
+
+func definitionError(err error) error {
+	if errors.Is(err, definition.ErrorNameNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+
+	return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/xenitab/opa-bundle-api/pkg/discovery"
+)
+
+func (client *Client) ReadDiscoveryConfig(c echo.Context) error {
+	config, err := client.discoveryClient.Get()
+	if err != nil {
+		return discoveryError(err)
+	}
+
+	return c.JSONBlob(http.StatusOK, config)
+}
+
+func (client *Client) UpdateDiscoveryConfig(c echo.Context) error {
+	var config json.RawMessage
+
+	if err := c.Bind(&config); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	err := client.discoveryClient.Set(config)
+	if err != nil {
+		return discoveryError(err)
+	}
+
+	return client.ReadDiscoveryConfig(c)
+}
+
+func (client *Client) DeleteDiscoveryConfig(c echo.Context) error {
+	err := client.discoveryClient.Delete()
+	if err != nil {
+		return discoveryError(err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func discoveryError(err error) error {
+	if errors.Is(err, discovery.ErrorConfigNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+
+	return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+}
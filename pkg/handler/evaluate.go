@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/xenitab/opa-bundle-api/pkg/replay"
+	"github.com/xenitab/opa-bundle-api/pkg/rule"
+)
+
+type evaluateRequest struct {
+	Input *interface{} `json:"input"`
+}
+
+type evaluateBatchRequest struct {
+	Inputs []interface{} `json:"inputs"`
+}
+
+type evaluateResponse struct {
+	Revision string `json:"revision"`
+	replay.Decision
+}
+
+var (
+	errorInputMissing = errors.New("Input missing")
+)
+
+// Evaluate evaluates the input with the current rules, or the rules of ?revision=, ?explain=full|notes|fails adds a trace
+func (client *Client) Evaluate(c echo.Context) error {
+	req := evaluateRequest{}
+
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if req.Input == nil {
+		return echo.NewHTTPError(http.StatusBadRequest, errorInputMissing.Error())
+	}
+
+	evaluation, err := client.replayClient.Evaluate([]interface{}{*req.Input}, c.QueryParam("revision"), c.QueryParam("explain"))
+	if err != nil {
+		return evaluateError(err)
+	}
+
+	return c.JSON(http.StatusOK, evaluateResponse{
+		Revision: evaluation.Revision,
+		Decision: evaluation.Decisions[0],
+	})
+}
+
+// EvaluateBatch evaluates all inputs with the same rules
+func (client *Client) EvaluateBatch(c echo.Context) error {
+	req := evaluateBatchRequest{}
+
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	evaluation, err := client.replayClient.Evaluate(req.Inputs, c.QueryParam("revision"), c.QueryParam("explain"))
+	if err != nil {
+		return evaluateError(err)
+	}
+
+	return c.JSON(http.StatusOK, evaluation)
+}
+
+func evaluateError(err error) error {
+	if errors.Is(err, rule.ErrorRevisionNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+
+	return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+}
@@ -5,34 +5,63 @@ import (
 
 	"github.com/labstack/echo/v4"
 	"github.com/xenitab/opa-bundle-api/pkg/bundle"
+	"github.com/xenitab/opa-bundle-api/pkg/definition"
+	"github.com/xenitab/opa-bundle-api/pkg/discovery"
 	"github.com/xenitab/opa-bundle-api/pkg/logs"
+	"github.com/xenitab/opa-bundle-api/pkg/policy"
 	"github.com/xenitab/opa-bundle-api/pkg/replay"
 	"github.com/xenitab/opa-bundle-api/pkg/rule"
+	"github.com/xenitab/opa-bundle-api/pkg/status"
 )
 
 type Options struct {
-	RuleClient   *rule.Client
-	BundleClient *bundle.Client
-	LogsClient   *logs.Client
-	ReplayClient *replay.Client
+	RuleClient       *rule.Client
+	BundleClient     *bundle.Client
+	LogsClient       *logs.Client
+	ReplayClient     *replay.Client
+	PolicyClient     *policy.Client
+	DefinitionClient *definition.Client
+	StatusClient     *status.Client
+	DiscoveryClient  *discovery.Client
+	JobClient        *replay.JobClient
 }
 
 type Client struct {
-	ruleClient   *rule.Client
-	bundleClient *bundle.Client
-	logsClient   *logs.Client
-	replayClient *replay.Client
+	ruleClient       *rule.Client
+	bundleClient     *bundle.Client
+	logsClient       *logs.Client
+	replayClient     *replay.Client
+	policyClient     *policy.Client
+	definitionClient *definition.Client
+	statusClient     *status.Client
+	discoveryClient  *discovery.Client
+	jobClient        *replay.JobClient
 }
 
 func NewClient(opts Options) *Client {
 	return &Client{
-		ruleClient:   opts.RuleClient,
-		bundleClient: opts.BundleClient,
-		logsClient:   opts.LogsClient,
-		replayClient: opts.ReplayClient,
+		ruleClient:       opts.RuleClient,
+		bundleClient:     opts.BundleClient,
+		logsClient:       opts.LogsClient,
+		replayClient:     opts.ReplayClient,
+		policyClient:     opts.PolicyClient,
+		definitionClient: opts.DefinitionClient,
+		statusClient:     opts.StatusClient,
+		discoveryClient:  opts.DiscoveryClient,
+		jobClient:        opts.JobClient,
 	}
 }
 
 func (client *Client) Default(c echo.Context) error {
 	return c.String(http.StatusOK, "Welcome to the opa-bundle-api")
 }
+
+// author returns who made the request, taken from the X-Author header or the client IP
+func author(c echo.Context) string {
+	headerAuthor := c.Request().Header.Get("X-Author")
+	if headerAuthor != "" {
+		return headerAuthor
+	}
+
+	return c.RealIP()
+}
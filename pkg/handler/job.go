@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/xenitab/opa-bundle-api/pkg/replay"
+)
+
+func (client *Client) CreateReplayJob(c echo.Context) error {
+	opts := replay.BatchOptions{}
+
+	if err := c.Bind(&opts); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	job, err := client.jobClient.Create(opts)
+	if errors.Is(err, replay.ErrorJobQueueFull) {
+		return echo.NewHTTPError(http.StatusServiceUnavailable, err.Error())
+	}
+
+	if err != nil {
+		return ruleError(err)
+	}
+
+	return c.JSON(http.StatusAccepted, job)
+}
+
+func (client *Client) ReadReplayJobs(c echo.Context) error {
+	return c.JSON(http.StatusOK, client.jobClient.GetAll())
+}
+
+func (client *Client) ReadReplayJob(c echo.Context) error {
+	job, err := client.jobClient.Get(c.Param("id"))
+	if err != nil {
+		return jobError(err)
+	}
+
+	return c.JSON(http.StatusOK, job)
+}
+
+// ReadReplayJobResults reads a page of the results with ?offset=, ?limit= and ?changed=true to only get the flips
+func (client *Client) ReadReplayJobResults(c echo.Context) error {
+	offset, err := intQueryParam(c, "offset", 0)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	limit, err := intQueryParam(c, "limit", replay.DefaultResultsLimit)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	changed := false
+	if c.QueryParam("changed") != "" {
+		changed, err = strconv.ParseBool(c.QueryParam("changed"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+	}
+
+	results, err := client.jobClient.GetResults(c.Param("id"), offset, limit, changed)
+	if err != nil {
+		return jobError(err)
+	}
+
+	return c.JSON(http.StatusOK, results)
+}
+
+func (client *Client) DeleteReplayJob(c echo.Context) error {
+	job, err := client.jobClient.Delete(c.Param("id"))
+	if err != nil {
+		return jobError(err)
+	}
+
+	return c.JSON(http.StatusOK, job)
+}
+
+func intQueryParam(c echo.Context, name string, defaultValue int) (int, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	return strconv.Atoi(value)
+}
+
+func jobError(err error) error {
+	if errors.Is(err, replay.ErrorJobNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+
+	return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/xenitab/opa-bundle-api/pkg/policy"
+)
+
+func (client *Client) ReadPolicies(c echo.Context) error {
+	policies := client.policyClient.GetAll()
+
+	return c.JSON(http.StatusOK, policies)
+}
+
+func (client *Client) CreatePolicy(c echo.Context) error {
+	p := policy.Policy{}
+
+	if err := c.Bind(&p); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	err := client.policyClient.Add(p)
+	if err != nil {
+		return policyError(err)
+	}
+
+	res, err := client.policyClient.Get(p.Name)
+	if err != nil {
+		return policyError(err)
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+func (client *Client) ReadPolicy(c echo.Context) error {
+	p, err := client.policyClient.Get(c.Param("name"))
+	if err != nil {
+		return policyError(err)
+	}
+
+	return c.JSON(http.StatusOK, p)
+}
+
+func (client *Client) UpdatePolicy(c echo.Context) error {
+	name := c.Param("name")
+	p := policy.Policy{}
+
+	if err := c.Bind(&p); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	err := client.policyClient.Set(name, p.Module)
+	if err != nil {
+		return policyError(err)
+	}
+
+	res, err := client.policyClient.Get(name)
+	if err != nil {
+		return policyError(err)
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+func (client *Client) DeletePolicy(c echo.Context) error {
+	err := client.policyClient.Delete(c.Param("name"))
+	if err != nil {
+		return policyError(err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// policyError returns the compile errors with their locations as the response body
+func policyError(err error) error {
+	var compileErr *policy.CompileError
+	if errors.As(err, &compileErr) {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, compileErr)
+	}
+
+	if errors.Is(err, policy.ErrorNameNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+
+	return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+}
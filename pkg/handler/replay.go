@@ -1,23 +1,37 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/labstack/echo/v4"
-	"github.com/xenitab/opa-bundle-api/pkg/bundle"
 	"github.com/xenitab/opa-bundle-api/pkg/replay"
 	"github.com/xenitab/opa-bundle-api/pkg/rule"
 )
 
+// ReplayLogWithCurrentRules replays with the current rules, or the rules of ?revision= (original is the revision the decision was made with), ?explain=full|notes|fails adds a trace
 func (client *Client) ReplayLogWithCurrentRules(c echo.Context) error {
 	decisionID := c.Param("decisionID")
+	revision := c.QueryParam("revision")
+	explain := c.QueryParam("explain")
+
+	var result replay.Result
+	var err error
+	if revision != "" {
+		result, err = client.replayClient.ReplayLogWithRevision(decisionID, revision, explain)
+	} else {
+		result, err = client.replayClient.ReplayLog(decisionID, explain)
+	}
+
+	if errors.Is(err, rule.ErrorRevisionNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
 
-	resultSet, err := client.replayClient.ReplayLog(decisionID)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	return c.JSON(http.StatusOK, resultSet)
+	return c.JSON(http.StatusOK, result)
 }
 
 func (client *Client) ReplayLogWithNewRules(c echo.Context) error {
@@ -28,37 +42,26 @@ func (client *Client) ReplayLogWithNewRules(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	tmpBundleClient := bundle.NewClient()
-	tmpRuleClient := rule.NewClient()
+	result, err := client.replayClient.ReplayLogWithRules(decisionID, rules, c.QueryParam("explain"))
+	if err != nil {
+		return ruleError(err)
+	}
 
-	for _, r := range rules {
-		opts := rule.Options{
-			Country:    r.Country,
-			City:       r.City,
-			Building:   r.Building,
-			Role:       r.Role,
-			DeviceType: r.DeviceType,
-			Action:     rule.ToAction(r.Action),
-		}
+	return c.JSON(http.StatusOK, result)
+}
 
-		_, err := tmpRuleClient.Add(opts)
-		if err != nil {
-			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
-		}
-	}
+// ReplayLogsWithNewRules replays all logs matching the filter against the posted rules and reports the decisions that flip
+func (client *Client) ReplayLogsWithNewRules(c echo.Context) error {
+	opts := replay.BatchOptions{}
 
-	replayOpts := replay.Options{
-		RuleClient:   tmpRuleClient,
-		BundleClient: tmpBundleClient,
-		LogsClient:   client.logsClient,
+	if err := c.Bind(&opts); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	tmpReplayClient := replay.NewClient(replayOpts)
-
-	resultSet, err := tmpReplayClient.ReplayLog(decisionID)
+	report, err := client.replayClient.ReplayBatch(c.Request().Context(), opts)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		return ruleError(err)
 	}
 
-	return c.JSON(http.StatusOK, resultSet)
+	return c.JSON(http.StatusOK, report)
 }
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/xenitab/opa-bundle-api/pkg/bundle"
+)
+
+func (client *Client) ReadRevisions(c echo.Context) error {
+	revisions := client.ruleClient.GetRevisions()
+
+	return c.JSON(http.StatusOK, revisions)
+}
+
+func (client *Client) ReadRevision(c echo.Context) error {
+	revision, err := client.ruleClient.GetRevision(ruleRevision(c.Param("revision")))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, revision)
+}
+
+func (client *Client) RollbackRevision(c echo.Context) error {
+	revision, err := client.ruleClient.Rollback(ruleRevision(c.Param("revision")), author(c), c.Request().Header.Get("If-Match"))
+	if err != nil {
+		return ruleError(err)
+	}
+
+	return c.JSON(http.StatusOK, revision)
+}
+
+func (client *Client) DiffRevisions(c echo.Context) error {
+	from := ruleRevision(c.QueryParam("from"))
+	if from == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Query parameter from is required")
+	}
+
+	to := ruleRevision(c.QueryParam("to"))
+	if to == "" {
+		current, err := client.ruleClient.Revision()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		to = current
+	}
+
+	diff, err := client.ruleClient.DiffRevisions(from, to)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, diff)
+}
+
+// ruleRevision returns the rule revision of a bundle revision, which is what the agents report when custom policies are used
+func ruleRevision(revision string) string {
+	ruleRevision, _ := bundle.SplitRevision(revision)
+	return ruleRevision
+}
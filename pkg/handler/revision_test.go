@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/xenitab/opa-bundle-api/pkg/policy"
+	"github.com/xenitab/opa-bundle-api/pkg/rule"
+)
+
+func TestRevisionsWithCustomPolicy(t *testing.T) {
+	ruleClient := rule.NewClient()
+
+	opts := rule.Options{
+		Country:    "Sweden",
+		City:       rule.WildcardString,
+		Building:   rule.WildcardString,
+		Role:       "sweden_admin",
+		DeviceType: rule.WildcardString,
+		Action:     rule.ActionAllow,
+	}
+
+	_, err := ruleClient.Add(opts)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	policyClient, err := policy.NewClient(policy.Options{IncludeStatic: true})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	err = policyClient.Add(policy.Policy{
+		Name:   "custom",
+		Module: "package custom\n\ndefault allow = false\n",
+	})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	client := NewClient(Options{
+		RuleClient:   ruleClient,
+		PolicyClient: policyClient,
+	})
+
+	// the revision an agent reports has the hash of the policies appended
+	bundleContent, err := client.currentContent()
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	opts.Role = "norway_admin"
+	_, err = ruleClient.Add(opts)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	e := echo.New()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("revision")
+	c.SetParamValues(bundleContent.Revision)
+
+	err = client.ReadRevision(c)
+	if err != nil {
+		t.Errorf("Expected the revision %s to be found: %q", bundleContent.Revision, err)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/?from="+bundleContent.Revision, nil)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+
+	err = client.DiffRevisions(c)
+	if err != nil {
+		t.Errorf("Expected the diff from %s to succeed: %q", bundleContent.Revision, err)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/", nil)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	c.SetParamNames("revision")
+	c.SetParamValues(bundleContent.Revision)
+
+	err = client.RollbackRevision(c)
+	if err != nil {
+		t.Fatalf("Expected the rollback to %s to succeed: %q", bundleContent.Revision, err)
+	}
+
+	rules, err := ruleClient.GetAll()
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	if len(rules) != 1 {
+		t.Errorf("Expected one rule after the rollback, got: %d", len(rules))
+	}
+}
+
+func TestRollbackMissingRevision(t *testing.T) {
+	client := NewClient(Options{
+		RuleClient: rule.NewClient(),
+	})
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("revision")
+	c.SetParamValues("missing")
+
+	err := client.RollbackRevision(c)
+
+	var httpErr *echo.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.Code != http.StatusNotFound {
+		t.Errorf("Expected the rollback to a missing revision to return 404: %v", err)
+	}
+}
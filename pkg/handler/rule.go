@@ -1,14 +1,20 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strings"
 
 	"github.com/labstack/echo/v4"
 	"github.com/xenitab/opa-bundle-api/pkg/rule"
 )
 
+const mimeMergePatch = "application/merge-patch+json"
+
 func (client *Client) ReadRules(c echo.Context) error {
-	rules, err := client.ruleClient.GetAll()
+	rules, revision, err := client.ruleClient.GetAllWithRevision()
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
@@ -17,6 +23,8 @@ func (client *Client) ReadRules(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
+	setETag(c, revision)
+
 	return c.JSON(http.StatusOK, rules)
 }
 
@@ -34,19 +42,37 @@ func (client *Client) CreateRule(c echo.Context) error {
 		Role:       r.Role,
 		DeviceType: r.DeviceType,
 		Action:     rule.ToAction(r.Action),
+		Author:     author(c),
 	}
 
 	id, err := client.ruleClient.Add(opts)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		return ruleError(err)
 	}
 
-	rule, err := client.ruleClient.Get(id)
+	created, err := client.ruleClient.Get(id)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	return c.JSON(http.StatusOK, rule)
+	// the rule is already created, a failed analysis only means there are no warnings
+	analysis, err := client.ruleClient.Analyze()
+	if err != nil {
+		return c.JSON(http.StatusOK, created)
+	}
+
+	res := createRuleResponse{
+		Rule:     created,
+		Warnings: analysis.Findings(id),
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// createRuleResponse is the created rule with warnings if it is a duplicate, redundant or shadowed
+type createRuleResponse struct {
+	rule.Rule
+	Warnings []rule.Finding `json:"warnings,omitempty"`
 }
 
 func (client *Client) ReadRule(c echo.Context) error {
@@ -55,12 +81,12 @@ func (client *Client) ReadRule(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	rule, err := client.ruleClient.Get(id)
+	r, err := client.ruleClient.Get(id)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		return ruleError(err)
 	}
 
-	return c.JSON(http.StatusOK, rule)
+	return ruleResponse(c, r)
 }
 
 func (client *Client) UpdateRule(c echo.Context) error {
@@ -75,6 +101,10 @@ func (client *Client) UpdateRule(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
+	if r.ID != rule.NullID && r.ID != id {
+		return echo.NewHTTPError(http.StatusBadRequest, rule.ErrorIdNotChangeable.Error())
+	}
+
 	opts := rule.Options{
 		Country:    r.Country,
 		City:       r.City,
@@ -82,19 +112,51 @@ func (client *Client) UpdateRule(c echo.Context) error {
 		Role:       r.Role,
 		DeviceType: r.DeviceType,
 		Action:     rule.ToAction(r.Action),
+		Author:     author(c),
+		IfMatch:    c.Request().Header.Get("If-Match"),
 	}
 
-	err = client.ruleClient.Set(id, opts)
+	err = client.ruleClient.Update(id, opts)
+	if err != nil {
+		return ruleError(err)
+	}
+
+	updated, err := client.ruleClient.Get(id)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	rule, err := client.ruleClient.Get(id)
+	return ruleResponse(c, updated)
+}
+
+// PatchRule changes the rule with a JSON Merge Patch, only the properties in the patch are changed
+func (client *Client) PatchRule(c echo.Context) error {
+	id, err := rule.StringToID(c.Param("id"))
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	return c.JSON(http.StatusOK, rule)
+	contentType := c.Request().Header.Get(echo.HeaderContentType)
+	if !strings.HasPrefix(contentType, mimeMergePatch) && !strings.HasPrefix(contentType, echo.MIMEApplicationJSON) {
+		return echo.NewHTTPError(http.StatusUnsupportedMediaType, fmt.Sprintf("Content-Type must be %s", mimeMergePatch))
+	}
+
+	patch, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	err = client.ruleClient.Patch(id, patch, author(c), c.Request().Header.Get("If-Match"))
+	if err != nil {
+		return ruleError(err)
+	}
+
+	patched, err := client.ruleClient.Get(id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return ruleResponse(c, patched)
 }
 
 func (client *Client) DeleteRule(c echo.Context) error {
@@ -103,10 +165,103 @@ func (client *Client) DeleteRule(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	err = client.ruleClient.Delete(id)
+	err = client.ruleClient.Delete(id, author(c), c.Request().Header.Get("If-Match"))
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		return ruleError(err)
 	}
 
 	return c.NoContent(http.StatusOK)
 }
+
+// BatchRules applies all operations at once with a single new revision, or none of them
+func (client *Client) BatchRules(c echo.Context) error {
+	batch := struct {
+		Operations []rule.Operation `json:"operations"`
+	}{}
+
+	if err := c.Bind(&batch); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	res, err := client.ruleClient.Batch(batch.Operations, author(c), c.Request().Header.Get("If-Match"))
+	if err != nil {
+		return ruleError(err)
+	}
+
+	setETag(c, res.Revision)
+
+	return c.JSON(http.StatusOK, res)
+}
+
+func (client *Client) ReadAccess(c echo.Context) error {
+	query := rule.AccessQuery{
+		Country:    c.QueryParam("country"),
+		City:       c.QueryParam("city"),
+		Building:   c.QueryParam("building"),
+		Role:       c.QueryParam("role"),
+		DeviceType: c.QueryParam("device_type"),
+	}
+
+	access, err := client.ruleClient.Access(query)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, access)
+}
+
+func (client *Client) ReadAnalysis(c echo.Context) error {
+	analysis, err := client.ruleClient.Analyze()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, analysis)
+}
+
+// ruleError returns the violations as the response body if the rules aren't valid
+func ruleError(err error) error {
+	var validationErr *rule.ValidationError
+	if errors.As(err, &validationErr) {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, validationErr)
+	}
+
+	// a batch gets the status the single-rule endpoints would have returned, with the errors of every operation
+	var batchErr *rule.BatchError
+	if errors.As(err, &batchErr) {
+		switch {
+		case errors.Is(batchErr, rule.ErrorIdNotChangeable):
+			return echo.NewHTTPError(http.StatusBadRequest, batchErr)
+		case errors.Is(batchErr, rule.ErrorPreconditionFailed):
+			return echo.NewHTTPError(http.StatusPreconditionFailed, batchErr)
+		default:
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, batchErr)
+		}
+	}
+
+	if errors.Is(err, rule.ErrorIdNotFound) || errors.Is(err, rule.ErrorRevisionNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+
+	if errors.Is(err, rule.ErrorPreconditionFailed) {
+		return echo.NewHTTPError(http.StatusPreconditionFailed, err.Error())
+	}
+
+	return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+}
+
+// ruleResponse sends the rule with its ETag, which can be used with If-Match to only change the rule if nobody else did
+func ruleResponse(c echo.Context, r rule.Rule) error {
+	etag, err := rule.ETag(r)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	setETag(c, etag)
+
+	return c.JSON(http.StatusOK, r)
+}
+
+func setETag(c echo.Context, etag string) {
+	c.Response().Header().Set("ETag", fmt.Sprintf("%q", etag))
+}
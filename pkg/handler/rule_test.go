@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/xenitab/opa-bundle-api/pkg/rule"
+)
+
+func TestRuleErrors(t *testing.T) {
+	ruleClient := rule.NewClient()
+
+	id, err := ruleClient.Add(rule.Options{
+		Country:    "Sweden",
+		City:       rule.WildcardString,
+		Building:   rule.WildcardString,
+		Role:       "sweden_admin",
+		DeviceType: rule.WildcardString,
+		Action:     rule.ActionAllow,
+	})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	client := NewClient(Options{
+		RuleClient: ruleClient,
+	})
+
+	cases := []struct {
+		name         string
+		handler      echo.HandlerFunc
+		method       string
+		id           string
+		body         string
+		ifMatch      string
+		expectedCode int
+	}{
+		{
+			name:         "read missing",
+			handler:      client.ReadRule,
+			method:       http.MethodGet,
+			id:           "1000",
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "update missing",
+			handler:      client.UpdateRule,
+			method:       http.MethodPut,
+			id:           "1000",
+			body:         `{"country":"Sweden","city":"*","building":"*","role":"user","device_type":"*","action":"allow"}`,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "patch missing",
+			handler:      client.PatchRule,
+			method:       http.MethodPatch,
+			id:           "1000",
+			body:         `{"action":"deny"}`,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "delete missing",
+			handler:      client.DeleteRule,
+			method:       http.MethodDelete,
+			id:           "1000",
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "update with stale etag",
+			handler:      client.UpdateRule,
+			method:       http.MethodPut,
+			id:           strconv.Itoa(id),
+			body:         `{"country":"Sweden","city":"*","building":"*","role":"user","device_type":"*","action":"allow"}`,
+			ifMatch:      `"stale"`,
+			expectedCode: http.StatusPreconditionFailed,
+		},
+		{
+			name:         "patch with stale etag",
+			handler:      client.PatchRule,
+			method:       http.MethodPatch,
+			id:           strconv.Itoa(id),
+			body:         `{"action":"deny"}`,
+			ifMatch:      `"stale"`,
+			expectedCode: http.StatusPreconditionFailed,
+		},
+		{
+			name:         "delete with stale etag",
+			handler:      client.DeleteRule,
+			method:       http.MethodDelete,
+			id:           strconv.Itoa(id),
+			ifMatch:      `"stale"`,
+			expectedCode: http.StatusPreconditionFailed,
+		},
+		{
+			name:         "update without action",
+			handler:      client.UpdateRule,
+			method:       http.MethodPut,
+			id:           strconv.Itoa(id),
+			body:         `{"country":"Sweden","city":"*","building":"*","role":"user","device_type":"*"}`,
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:         "batch with stale etag",
+			handler:      client.BatchRules,
+			method:       http.MethodPost,
+			body:         `{"operations":[{"op":"delete","id":` + strconv.Itoa(id) + `,"if_match":"\"stale\""}]}`,
+			expectedCode: http.StatusPreconditionFailed,
+		},
+		{
+			name:         "batch update of the id",
+			handler:      client.BatchRules,
+			method:       http.MethodPost,
+			body:         `{"operations":[{"op":"update","id":` + strconv.Itoa(id) + `,"rule":{"id":1000,"country":"Sweden","city":"*","building":"*","role":"user","device_type":"*","action":"allow"}}]}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "batch without action",
+			handler:      client.BatchRules,
+			method:       http.MethodPost,
+			body:         `{"operations":[{"op":"create","rule":{"country":"Sweden","city":"*","building":"*","role":"user","device_type":"*"}}]}`,
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:         "patch with unknown action",
+			handler:      client.PatchRule,
+			method:       http.MethodPatch,
+			id:           strconv.Itoa(id),
+			body:         `{"action":"maybe"}`,
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+	}
+
+	e := echo.New()
+
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, "/", strings.NewReader(c.body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if c.ifMatch != "" {
+			req.Header.Set("If-Match", c.ifMatch)
+		}
+
+		rec := httptest.NewRecorder()
+		ctx := e.NewContext(req, rec)
+		ctx.SetParamNames("id")
+		ctx.SetParamValues(c.id)
+
+		err := c.handler(ctx)
+
+		var httpErr *echo.HTTPError
+		if !errors.As(err, &httpErr) {
+			t.Errorf("Expected %s to return an HTTP error, got: %v", c.name, err)
+			continue
+		}
+
+		if httpErr.Code != c.expectedCode {
+			t.Errorf("Expected %s to return %d, got: %d", c.name, c.expectedCode, httpErr.Code)
+		}
+	}
+
+	r, err := ruleClient.Get(id)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	if r.Role != "sweden_admin" || r.Action != "allow" {
+		t.Errorf("Expected the rule to be unchanged, got: %v", r)
+	}
+}
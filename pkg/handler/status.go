@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/xenitab/opa-bundle-api/pkg/status"
+)
+
+func (client *Client) CreateStatus(c echo.Context) error {
+	report := status.Report{}
+
+	if err := c.Bind(&report); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	_, err := client.statusClient.Update(report)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// ReadAgents reads all agents, optionally only the ones with the active revision in ?revision= or ?failed=true|false
+func (client *Client) ReadAgents(c echo.Context) error {
+	revision := c.QueryParam("revision")
+
+	var failed *bool
+	if c.QueryParam("failed") != "" {
+		b, err := strconv.ParseBool(c.QueryParam("failed"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		failed = &b
+	}
+
+	agents := []status.Agent{}
+	for _, agent := range client.statusClient.GetAll() {
+		if failed != nil && agent.Failed != *failed {
+			continue
+		}
+
+		if revision != "" && !hasRevision(agent, revision) {
+			continue
+		}
+
+		agents = append(agents, agent)
+	}
+
+	return c.JSON(http.StatusOK, agents)
+}
+
+func (client *Client) ReadAgent(c echo.Context) error {
+	agent, err := client.statusClient.Get(c.Param("id"))
+	if errors.Is(err, status.ErrorIDNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, agent)
+}
+
+func hasRevision(agent status.Agent, revision string) bool {
+	for _, r := range agent.Revisions {
+		if r == revision {
+			return true
+		}
+	}
+
+	return false
+}
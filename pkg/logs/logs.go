@@ -3,6 +3,7 @@ package logs
 import (
 	"errors"
 	"sync"
+	"time"
 
 	opalogs "github.com/open-policy-agent/opa/plugins/logs"
 )
@@ -11,80 +12,348 @@ var (
 	NullOpaEvent         = opalogs.EventV1{}
 	ErrorIDAlreadyExists = errors.New("DecisionID already exists")
 	ErrorIDNotFound      = errors.New("DecisionID not found")
+	DefaultSegmentSize   = int64(8 * 1024 * 1024)
+	DefaultEvictInterval = time.Minute
 )
 
 type DecisionID = string
 
+// Options configures the store and retention of the decision logs, zero values mean no limit
+type Options struct {
+	Store       Store
+	MaxAge      time.Duration
+	MaxSize     int64
+	SegmentSize int64
+}
+
+// segment is appended to without holding the lock of the client, pending keeps it from being evicted in the meantime
+type segment struct {
+	sync.Mutex
+	id   int
+	size int64
+	// created limits how long a segment is appended to, modified is the time of the newest decision
+	created   time.Time
+	modified  time.Time
+	locations []Location
+	pending   int
+}
+
+// location is where the event of a decision is stored, only the locations are kept in memory
+type location struct {
+	segment *segment
+	offset  int64
+	length  int64
+}
+
 type Client struct {
 	sync.RWMutex
-	logs map[DecisionID]opalogs.EventV1
+	logs map[DecisionID]location
+	// creating is the decisions that are being appended, so they can't be created twice
+	creating    map[DecisionID]bool
+	segments    []*segment
+	segmentID   int
+	size        int64
+	store       Store
+	maxAge      time.Duration
+	maxSize     int64
+	segmentSize int64
+	evict       chan struct{}
+	done        chan struct{}
+	closeOnce   sync.Once
 }
 
-func NewClient() *Client {
-	return &Client{
-		logs: make(map[DecisionID]opalogs.EventV1),
+// NewClient returns a Client with the segments loaded from the store and eviction running in the background
+func NewClient(opts Options) (*Client, error) {
+	store := opts.Store
+	if store == nil {
+		store = NewMemoryStore()
+	}
+
+	segmentSize := opts.SegmentSize
+	if segmentSize <= 0 {
+		segmentSize = DefaultSegmentSize
+	}
+
+	// keep the eviction granularity reasonable compared to the total size
+	if opts.MaxSize > 0 && opts.MaxSize/8 < segmentSize {
+		segmentSize = opts.MaxSize / 8
+	}
+
+	client := &Client{
+		logs:        make(map[DecisionID]location),
+		creating:    make(map[DecisionID]bool),
+		store:       store,
+		maxAge:      opts.MaxAge,
+		maxSize:     opts.MaxSize,
+		segmentSize: segmentSize,
+		evict:       make(chan struct{}, 1),
+		done:        make(chan struct{}),
+	}
+
+	segments, err := store.Load()
+	if err != nil {
+		return nil, err
 	}
+
+	for _, s := range segments {
+		loaded := &segment{
+			id:       s.ID,
+			size:     s.Size,
+			created:  s.Modified,
+			modified: s.Modified,
+		}
+
+		client.addLocationsWithoutLock(loaded, s.Locations)
+
+		client.segments = append(client.segments, loaded)
+		client.segmentID = s.ID
+		client.size += s.Size
+	}
+
+	if client.maxAge > 0 || client.maxSize > 0 {
+		client.evictExpired()
+		go client.runEviction()
+	}
+
+	return client, nil
+}
+
+// Close stops the background eviction
+func (client *Client) Close() {
+	client.closeOnce.Do(func() {
+		close(client.done)
+	})
 }
 
 func (client *Client) Create(log opalogs.EventV1) error {
+	return client.CreateMultiple([]opalogs.EventV1{log})
+}
+
+// CreateMultiple appends the logs to the active segment, the store is written without holding the lock of the client
+func (client *Client) CreateMultiple(logs []opalogs.EventV1) error {
+	accepted, active, createErr := client.reserve(logs)
+
+	if len(accepted) > 0 {
+		active.Lock()
+		locations, err := client.store.Append(active.id, accepted)
+		active.Unlock()
+
+		client.commit(active, accepted, locations, err)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	client.signalEviction()
+
+	return createErr
+}
+
+// reserve returns the logs that can be created and the segment they are appended to
+func (client *Client) reserve(logs []opalogs.EventV1) ([]opalogs.EventV1, *segment, error) {
 	client.Lock()
 	defer client.Unlock()
 
-	_, found := client.logs[log.DecisionID]
-	if found {
-		return ErrorIDAlreadyExists
+	var accepted []opalogs.EventV1
+	var createErr error
+
+	for _, log := range logs {
+		_, found := client.logs[log.DecisionID]
+		if found || client.creating[log.DecisionID] {
+			createErr = ErrorIDAlreadyExists
+			break
+		}
+
+		client.creating[log.DecisionID] = true
+		accepted = append(accepted, log)
 	}
 
-	client.logs[log.DecisionID] = log
+	if len(accepted) == 0 {
+		return nil, nil, createErr
+	}
+
+	active := client.activeSegmentWithoutLock()
+	active.pending++
 
-	return nil
+	return accepted, active, createErr
 }
 
-func (client *Client) CreateMultiple(logs []opalogs.EventV1) error {
+// commit makes the appended logs readable, or releases their decision IDs if the append failed
+func (client *Client) commit(active *segment, logs []opalogs.EventV1, locations []Location, appendErr error) {
 	client.Lock()
 	defer client.Unlock()
 
+	active.pending--
+
 	for _, log := range logs {
-		err := client.createWithoutLock(log)
-		if err != nil {
-			return err
-		}
+		delete(client.creating, log.DecisionID)
+	}
+
+	if appendErr != nil {
+		return
 	}
 
-	return nil
+	client.addLocationsWithoutLock(active, locations)
+
+	var size int64
+	for _, l := range locations {
+		size += l.Length
+	}
+
+	active.size += size
+	active.modified = time.Now()
+	client.size += size
 }
 
-func (client *Client) createWithoutLock(log opalogs.EventV1) error {
-	_, found := client.logs[log.DecisionID]
-	if found {
-		return ErrorIDAlreadyExists
+func (client *Client) addLocationsWithoutLock(s *segment, locations []Location) {
+	for _, l := range locations {
+		client.logs[l.DecisionID] = location{
+			segment: s,
+			offset:  l.Offset,
+			length:  l.Length,
+		}
+
+		s.locations = append(s.locations, l)
+	}
+}
+
+func (client *Client) activeSegmentWithoutLock() *segment {
+	now := time.Now()
+
+	// a segment is only evicted by age when its newest decision expires, so it can't be appended to forever
+	if len(client.segments) > 0 {
+		last := client.segments[len(client.segments)-1]
+		tooOld := client.maxAge > 0 && now.Sub(last.created) >= client.maxAge/8
+		if last.size < client.segmentSize && !tooOld {
+			return last
+		}
+	}
+
+	// segment IDs are never reused, an evicted segment may still be in the process of being removed
+	client.segmentID++
+
+	active := &segment{
+		id:       client.segmentID,
+		created:  now,
+		modified: now,
 	}
 
-	client.logs[log.DecisionID] = log
+	client.segments = append(client.segments, active)
 
-	return nil
+	return active
 }
 
+// Read returns the log of the decision, the event is read from the store without holding the lock
 func (client *Client) Read(id DecisionID) (opalogs.EventV1, error) {
 	client.RLock()
-	defer client.RUnlock()
+	l, found := client.logs[id]
+	client.RUnlock()
 
-	log, found := client.logs[id]
 	if !found {
 		return NullOpaEvent, ErrorIDNotFound
 	}
 
-	return log, nil
+	logs, err := client.store.Read(l.segment.id, []Location{{DecisionID: id, Offset: l.offset, Length: l.length}})
+	if errors.Is(err, ErrorSegmentNotFound) {
+		// evicted after the location was found
+		return NullOpaEvent, ErrorIDNotFound
+	}
+
+	if err != nil {
+		return NullOpaEvent, err
+	}
+
+	return logs[0], nil
 }
 
+// ReadAll returns the logs of every segment, oldest first. Segments that are evicted while reading are skipped
 func (client *Client) ReadAll() []opalogs.EventV1 {
 	client.RLock()
-	defer client.RUnlock()
+	segments := make([]Segment, 0, len(client.segments))
+	for _, s := range client.segments {
+		segments = append(segments, Segment{ID: s.id, Locations: s.locations})
+	}
+	client.RUnlock()
 
 	var logs []opalogs.EventV1
-	for _, log := range client.logs {
-		logs = append(logs, log)
+	for _, s := range segments {
+		if len(s.Locations) == 0 {
+			continue
+		}
+
+		segmentLogs, err := client.store.Read(s.ID, s.Locations)
+		if err != nil {
+			continue
+		}
+
+		logs = append(logs, segmentLogs...)
 	}
 
 	return logs
 }
+
+// signalEviction wakes up the eviction without ever waiting for it
+func (client *Client) signalEviction() {
+	if client.maxAge <= 0 && client.maxSize <= 0 {
+		return
+	}
+
+	select {
+	case client.evict <- struct{}{}:
+	default:
+	}
+}
+
+func (client *Client) runEviction() {
+	ticker := time.NewTicker(DefaultEvictInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-client.done:
+			return
+		case <-ticker.C:
+			client.evictExpired()
+		case <-client.evict:
+			client.evictExpired()
+		}
+	}
+}
+
+// evictExpired drops the oldest segments until the retention is fulfilled, the files are removed without holding the lock
+func (client *Client) evictExpired() {
+	victims := client.selectVictims()
+
+	for _, id := range victims {
+		_ = client.store.Remove(id)
+	}
+}
+
+func (client *Client) selectVictims() []int {
+	client.Lock()
+	defer client.Unlock()
+
+	now := time.Now()
+	var victims []int
+
+	for len(client.segments) > 0 {
+		oldest := client.segments[0]
+
+		expired := client.maxAge > 0 && now.Sub(oldest.modified) > client.maxAge
+		oversized := client.maxSize > 0 && client.size > client.maxSize
+		if (!expired && !oversized) || oldest.pending > 0 {
+			break
+		}
+
+		for _, l := range oldest.locations {
+			delete(client.logs, l.DecisionID)
+		}
+
+		client.size -= oldest.size
+		client.segments = client.segments[1:]
+		victims = append(victims, oldest.id)
+	}
+
+	return victims
+}
@@ -0,0 +1,215 @@
+package logs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	opalogs "github.com/open-policy-agent/opa/plugins/logs"
+)
+
+func TestFileStoreRetention(t *testing.T) {
+	dir := t.TempDir()
+
+	opts := Options{
+		Store:       NewFileStore(dir),
+		MaxSize:     4096,
+		SegmentSize: 512,
+	}
+
+	client, err := NewClient(opts)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	for i := 0; i < 100; i++ {
+		err := client.Create(opalogs.EventV1{
+			DecisionID: fmt.Sprintf("decision-%d", i),
+			Path:       "rule/allow",
+		})
+		if err != nil {
+			t.Fatalf("Expected err to be nil: %q", err)
+		}
+	}
+
+	client.Close()
+	client.evictExpired()
+
+	if client.size > opts.MaxSize {
+		t.Errorf("Expected size to be at most %d but was: %d", opts.MaxSize, client.size)
+	}
+
+	_, err = client.Read("decision-0")
+	if err != ErrorIDNotFound {
+		t.Errorf("Expected oldest decision to be evicted but err was: %q", err)
+	}
+
+	restartedClient, err := NewClient(opts)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	defer restartedClient.Close()
+
+	_, err = restartedClient.Read("decision-99")
+	if err != nil {
+		t.Errorf("Expected newest decision to survive restart: %q", err)
+	}
+
+	err = restartedClient.Create(opalogs.EventV1{DecisionID: "decision-99"})
+	if err != ErrorIDAlreadyExists {
+		t.Errorf("Expected err to be ErrorIDAlreadyExists but was: %q", err)
+	}
+}
+
+func TestFileStoreReadFromSegment(t *testing.T) {
+	dir := t.TempDir()
+	opts := Options{
+		Store: NewFileStore(dir),
+	}
+
+	client, err := NewClient(opts)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	err = client.Create(opalogs.EventV1{DecisionID: "decision-0", Path: "rule/allow"})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	client.Close()
+
+	// simulate a crash in the middle of an append
+	segmentFiles, err := filepath.Glob(filepath.Join(dir, "*"+segmentFileExtension))
+	if err != nil || len(segmentFiles) != 1 {
+		t.Fatalf("Expected one segment file but was: %v (%v)", segmentFiles, err)
+	}
+
+	file, err := os.OpenFile(segmentFiles[0], os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	_, err = file.WriteString(`{"decision_id":"decision-partial","pa`)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	file.Close()
+
+	restartedClient, err := NewClient(opts)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	err = restartedClient.Create(opalogs.EventV1{DecisionID: "decision-1", Path: "rule/deny"})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	restartedClient.Close()
+
+	restartedClient, err = NewClient(opts)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	defer restartedClient.Close()
+
+	for id, path := range map[DecisionID]string{"decision-0": "rule/allow", "decision-1": "rule/deny"} {
+		event, err := restartedClient.Read(id)
+		if err != nil {
+			t.Fatalf("Expected err to be nil: %q", err)
+		}
+
+		if event.Path != path {
+			t.Errorf("Expected path of %s to be %q but was: %q", id, path, event.Path)
+		}
+	}
+
+	if len(restartedClient.ReadAll()) != 2 {
+		t.Errorf("Expected 2 decisions but was: %d", len(restartedClient.ReadAll()))
+	}
+}
+
+func TestConcurrentCreateAndRead(t *testing.T) {
+	client, err := NewClient(Options{
+		Store:       NewFileStore(t.TempDir()),
+		SegmentSize: 512,
+	})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	defer client.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < 25; j++ {
+				id := fmt.Sprintf("decision-%d-%d", i, j)
+				err := client.Create(opalogs.EventV1{DecisionID: id, Path: id})
+				if err != nil {
+					t.Errorf("Expected err to be nil: %q", err)
+					return
+				}
+
+				event, err := client.Read(id)
+				if err != nil {
+					t.Errorf("Expected err to be nil: %q", err)
+					return
+				}
+
+				if event.Path != id {
+					t.Errorf("Expected path to be %q but was: %q", id, event.Path)
+				}
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	if len(client.ReadAll()) != 200 {
+		t.Errorf("Expected 200 decisions but was: %d", len(client.ReadAll()))
+	}
+}
+
+func TestAgeRetentionWithSteadyTraffic(t *testing.T) {
+	client, err := NewClient(Options{
+		MaxAge: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	defer client.Close()
+
+	// the segment never fills up, but it shouldn't be kept alive by the new decisions
+	for i := 0; i < 10; i++ {
+		err := client.Create(opalogs.EventV1{DecisionID: fmt.Sprintf("decision-%d", i)})
+		if err != nil {
+			t.Fatalf("Expected err to be nil: %q", err)
+		}
+
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	client.evictExpired()
+
+	_, err = client.Read("decision-0")
+	if err != ErrorIDNotFound {
+		t.Errorf("Expected oldest decision to be evicted but err was: %q", err)
+	}
+
+	_, err = client.Read("decision-9")
+	if err != nil {
+		t.Errorf("Expected newest decision to be kept: %q", err)
+	}
+}
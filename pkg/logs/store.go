@@ -0,0 +1,330 @@
+package logs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	opalogs "github.com/open-policy-agent/opa/plugins/logs"
+)
+
+var (
+	segmentFileExtension = ".jsonl"
+	ErrorSegmentNotFound = errors.New("Segment not found")
+)
+
+// Segment is a chunk of decision logs that is appended to and evicted as a whole
+type Segment struct {
+	ID        int
+	Size      int64
+	Modified  time.Time
+	Locations []Location
+}
+
+// Location is where the event of a decision is stored in a segment
+type Location struct {
+	DecisionID DecisionID
+	Offset     int64
+	Length     int64
+}
+
+// Store persists decision logs as append-only segments, Append is never called concurrently for the same segment
+type Store interface {
+	// Load returns the segments with the locations of the events, the events are read with Read
+	Load() ([]Segment, error)
+	Append(segmentID int, events []opalogs.EventV1) ([]Location, error)
+	Read(segmentID int, locations []Location) ([]opalogs.EventV1, error)
+	Remove(segmentID int) error
+}
+
+type memoryStore struct {
+	sync.RWMutex
+	segments map[int][]byte
+}
+
+// NewMemoryStore returns a Store that only keeps the decision logs in memory
+func NewMemoryStore() Store {
+	return &memoryStore{
+		segments: make(map[int][]byte),
+	}
+}
+
+func (store *memoryStore) Load() ([]Segment, error) {
+	return nil, nil
+}
+
+func (store *memoryStore) Append(segmentID int, events []opalogs.EventV1) ([]Location, error) {
+	data, lengths, err := encodeEvents(events)
+	if err != nil {
+		return nil, err
+	}
+
+	store.Lock()
+	defer store.Unlock()
+
+	offset := int64(len(store.segments[segmentID]))
+	store.segments[segmentID] = append(store.segments[segmentID], data...)
+
+	return locations(events, offset, lengths), nil
+}
+
+func (store *memoryStore) Read(segmentID int, locations []Location) ([]opalogs.EventV1, error) {
+	store.RLock()
+	defer store.RUnlock()
+
+	data, found := store.segments[segmentID]
+	if !found {
+		return nil, ErrorSegmentNotFound
+	}
+
+	return decodeEvents(bytes.NewReader(data), locations)
+}
+
+func (store *memoryStore) Remove(segmentID int) error {
+	store.Lock()
+	defer store.Unlock()
+
+	delete(store.segments, segmentID)
+
+	return nil
+}
+
+type fileStore struct {
+	dir string
+}
+
+// NewFileStore returns a Store that keeps every segment as a JSON lines file in dir
+func NewFileStore(dir string) Store {
+	return &fileStore{
+		dir: dir,
+	}
+}
+
+func (store *fileStore) Load() ([]Segment, error) {
+	err := os.MkdirAll(store.dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(store.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []Segment
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentFileExtension) {
+			continue
+		}
+
+		id, err := strconv.Atoi(strings.TrimSuffix(name, segmentFileExtension))
+		if err != nil {
+			continue
+		}
+
+		segment, err := store.indexSegment(id)
+		if err != nil {
+			return nil, err
+		}
+
+		segments = append(segments, segment)
+	}
+
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].ID < segments[j].ID
+	})
+
+	return segments, nil
+}
+
+func (store *fileStore) Append(segmentID int, events []opalogs.EventV1) ([]Location, error) {
+	data, lengths, err := encodeEvents(events)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(store.segmentPath(segmentID), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	_, err = file.Write(data)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	err = file.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return locations(events, fileInfo.Size(), lengths), nil
+}
+
+func (store *fileStore) Read(segmentID int, locations []Location) ([]opalogs.EventV1, error) {
+	file, err := os.Open(store.segmentPath(segmentID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrorSegmentNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	return decodeEvents(file, locations)
+}
+
+func (store *fileStore) Remove(segmentID int) error {
+	err := os.Remove(store.segmentPath(segmentID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+func (store *fileStore) segmentPath(segmentID int) string {
+	return filepath.Join(store.dir, fmt.Sprintf("%020d%s", segmentID, segmentFileExtension))
+}
+
+// indexSegment returns the locations of the events in the segment, only the decision IDs are decoded
+func (store *fileStore) indexSegment(segmentID int) (Segment, error) {
+	filePath := store.segmentPath(segmentID)
+
+	fileInfo, err := os.Stat(filePath)
+	if err != nil {
+		return Segment{}, err
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return Segment{}, err
+	}
+
+	defer file.Close()
+
+	var locations []Location
+	var offset int64
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return Segment{}, err
+		}
+
+		length := int64(len(line))
+
+		var event struct {
+			DecisionID DecisionID `json:"decision_id"`
+		}
+
+		err = json.Unmarshal(line, &event)
+		if err == nil {
+			locations = append(locations, Location{
+				DecisionID: event.DecisionID,
+				Offset:     offset,
+				Length:     length,
+			})
+		}
+
+		offset += length
+	}
+
+	// a partially written last line from a crash is removed, otherwise the next event would be appended to it
+	size := fileInfo.Size()
+	if offset < size {
+		err := os.Truncate(filePath, offset)
+		if err != nil {
+			return Segment{}, err
+		}
+
+		size = offset
+	}
+
+	return Segment{
+		ID:        segmentID,
+		Size:      size,
+		Modified:  fileInfo.ModTime(),
+		Locations: locations,
+	}, nil
+}
+
+// encodeEvents returns the events as JSON lines and the length of every line
+func encodeEvents(events []opalogs.EventV1) ([]byte, []int64, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	lengths := make([]int64, len(events))
+
+	for i := range events {
+		before := buf.Len()
+
+		err := encoder.Encode(&events[i])
+		if err != nil {
+			return nil, nil, err
+		}
+
+		lengths[i] = int64(buf.Len() - before)
+	}
+
+	return buf.Bytes(), lengths, nil
+}
+
+func decodeEvents(reader io.ReaderAt, locations []Location) ([]opalogs.EventV1, error) {
+	events := make([]opalogs.EventV1, len(locations))
+
+	for i, location := range locations {
+		data := make([]byte, location.Length)
+		_, err := reader.ReadAt(data, location.Offset)
+		if err != nil {
+			return nil, err
+		}
+
+		err = json.Unmarshal(data, &events[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return events, nil
+}
+
+func locations(events []opalogs.EventV1, offset int64, lengths []int64) []Location {
+	res := make([]Location, len(events))
+
+	for i := range events {
+		res[i] = Location{
+			DecisionID: events[i].DecisionID,
+			Offset:     offset,
+			Length:     lengths[i],
+		}
+
+		offset += lengths[i]
+	}
+
+	return res
+}
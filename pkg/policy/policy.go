@@ -0,0 +1,338 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/xenitab/opa-bundle-api/pkg/bundle"
+	"github.com/xenitab/opa-bundle-api/pkg/util"
+)
+
+var (
+	NullPolicy              = Policy{}
+	ErrorNameAlreadyExists  = errors.New("Name already exists")
+	ErrorNameNotFound       = errors.New("Name not found")
+	ErrorNameNotValid       = errors.New("Name not valid, only letters, digits, - and _ are allowed")
+	ErrorModuleNotValid     = errors.New("Module not valid")
+	validName               = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+	customModuleDirectory   = "policies"
+	customModuleFileSuffix  = ".rego"
+	compileErrorMessage     = "Unable to compile modules"
+	unknownCompileErrorCode = "rego_compile_error"
+)
+
+// Policy is a custom rego module that is added to the bundle
+type Policy struct {
+	Name   string `json:"name"`
+	Module string `json:"module"`
+}
+
+// CompileErrorDetail is a single error from parsing or compiling the modules
+type CompileErrorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	File    string `json:"file"`
+	Row     int    `json:"row"`
+	Col     int    `json:"col"`
+}
+
+// CompileError is returned when the modules can't be compiled, with the location of every error
+type CompileError struct {
+	Message string               `json:"message"`
+	Errors  []CompileErrorDetail `json:"errors"`
+}
+
+func (e *CompileError) Error() string {
+	var lines []string
+	for _, detail := range e.Errors {
+		lines = append(lines, fmt.Sprintf("%s:%d:%d: %s", detail.File, detail.Row, detail.Col, detail.Message))
+	}
+
+	return fmt.Sprintf("%s: %s", e.Message, strings.Join(lines, ", "))
+}
+
+// State is what the policy client saves in the store, the static modules are not part of it
+type State struct {
+	Policies []Policy `json:"policies"`
+}
+
+// Options configures where the policies are persisted and if the static modules are included in the bundle
+type Options struct {
+	Store         util.Store
+	IncludeStatic bool
+}
+
+type Client struct {
+	sync.RWMutex
+	policies      map[string]Policy
+	store         util.Store
+	includeStatic bool
+	changed       chan struct{}
+}
+
+// NewClient returns a Client with the policies loaded from the store
+func NewClient(opts Options) (*Client, error) {
+	store := opts.Store
+	if store == nil {
+		store = util.NewMemoryStore()
+	}
+
+	var state State
+	err := store.Load(&state)
+	if err != nil {
+		return nil, err
+	}
+
+	policies := make(map[string]Policy)
+	for _, policy := range state.Policies {
+		policies[policy.Name] = policy
+	}
+
+	return &Client{
+		policies:      policies,
+		store:         store,
+		includeStatic: opts.IncludeStatic,
+		changed:       make(chan struct{}),
+	}, nil
+}
+
+func (client *Client) Add(policy Policy) error {
+	client.Lock()
+	defer client.Unlock()
+
+	if !validName.MatchString(policy.Name) {
+		return ErrorNameNotValid
+	}
+
+	_, found := client.policies[policy.Name]
+	if found {
+		return ErrorNameAlreadyExists
+	}
+
+	policies := client.copyPolicies()
+	policies[policy.Name] = policy
+
+	return client.apply(policies)
+}
+
+func (client *Client) Get(name string) (Policy, error) {
+	client.RLock()
+	defer client.RUnlock()
+
+	policy, found := client.policies[name]
+	if !found {
+		return NullPolicy, ErrorNameNotFound
+	}
+
+	return policy, nil
+}
+
+func (client *Client) GetAll() []Policy {
+	client.RLock()
+	defer client.RUnlock()
+
+	return sortedPolicies(client.policies)
+}
+
+func (client *Client) Set(name string, module string) error {
+	client.Lock()
+	defer client.Unlock()
+
+	_, found := client.policies[name]
+	if !found {
+		return ErrorNameNotFound
+	}
+
+	policies := client.copyPolicies()
+	policies[name] = Policy{
+		Name:   name,
+		Module: module,
+	}
+
+	return client.apply(policies)
+}
+
+// Delete removes the policy, as long as the remaining modules still compile
+func (client *Client) Delete(name string) error {
+	client.Lock()
+	defer client.Unlock()
+
+	_, found := client.policies[name]
+	if !found {
+		return ErrorNameNotFound
+	}
+
+	policies := client.copyPolicies()
+	delete(policies, name)
+
+	return client.apply(policies)
+}
+
+// GetModules returns all modules that should be included in the bundle
+func (client *Client) GetModules() ([]bundle.Module, error) {
+	client.RLock()
+	defer client.RUnlock()
+
+	return client.modules(client.policies)
+}
+
+// Changed returns a channel that is closed the next time the policies change
+func (client *Client) Changed() <-chan struct{} {
+	client.RLock()
+	defer client.RUnlock()
+
+	return client.changed
+}
+
+// apply compiles and persists the new policies before making them the current policies
+func (client *Client) apply(policies map[string]Policy) error {
+	modules, err := client.modules(policies)
+	if err != nil {
+		return err
+	}
+
+	err = Compile(modules)
+	if err != nil {
+		return err
+	}
+
+	err = client.store.Save(&State{Policies: sortedPolicies(policies)})
+	if err != nil {
+		return err
+	}
+
+	client.policies = policies
+
+	close(client.changed)
+	client.changed = make(chan struct{})
+
+	return nil
+}
+
+func (client *Client) modules(policies map[string]Policy) ([]bundle.Module, error) {
+	var modules []bundle.Module
+
+	if client.includeStatic {
+		staticModules, err := bundle.StaticModules()
+		if err != nil {
+			return nil, err
+		}
+
+		modules = append(modules, staticModules...)
+	}
+
+	for _, policy := range sortedPolicies(policies) {
+		modules = append(modules, bundle.Module{
+			Path: ModulePath(policy.Name),
+			Raw:  []byte(policy.Module),
+		})
+	}
+
+	return modules, nil
+}
+
+func (client *Client) copyPolicies() map[string]Policy {
+	policies := make(map[string]Policy, len(client.policies))
+	for k, v := range client.policies {
+		policies[k] = v
+	}
+
+	return policies
+}
+
+// ModulePath returns the path of the policy in the bundle
+func ModulePath(name string) string {
+	return fmt.Sprintf("%s/%s%s", customModuleDirectory, name, customModuleFileSuffix)
+}
+
+// Compile parses and compiles the modules together, returning a CompileError with the location of every error
+func Compile(modules []bundle.Module) error {
+	parsed := make(map[string]*ast.Module)
+	compileErr := &CompileError{
+		Message: compileErrorMessage,
+	}
+
+	for _, module := range modules {
+		m, err := ast.ParseModule(module.Path, string(module.Raw))
+		if err != nil {
+			compileErr.Errors = append(compileErr.Errors, toCompileErrorDetails(module.Path, err)...)
+			continue
+		}
+
+		if m == nil {
+			compileErr.Errors = append(compileErr.Errors, CompileErrorDetail{
+				Message: ErrorModuleNotValid.Error(),
+				File:    module.Path,
+			})
+			continue
+		}
+
+		parsed[module.Path] = m
+	}
+
+	if len(compileErr.Errors) > 0 {
+		return compileErr
+	}
+
+	compiler := ast.NewCompiler()
+	compiler.Compile(parsed)
+	if compiler.Failed() {
+		compileErr.Errors = toCompileErrorDetails("", compiler.Errors)
+		return compileErr
+	}
+
+	return nil
+}
+
+func toCompileErrorDetails(file string, err error) []CompileErrorDetail {
+	var astErrors ast.Errors
+	if !errors.As(err, &astErrors) {
+		return []CompileErrorDetail{
+			{
+				Code:    unknownCompileErrorCode,
+				Message: err.Error(),
+				File:    file,
+			},
+		}
+	}
+
+	var details []CompileErrorDetail
+	for _, astErr := range astErrors {
+		detail := CompileErrorDetail{
+			Code:    astErr.Code,
+			Message: astErr.Message,
+			File:    file,
+		}
+
+		if astErr.Location != nil {
+			detail.File = astErr.Location.File
+			detail.Row = astErr.Location.Row
+			detail.Col = astErr.Location.Col
+		}
+
+		details = append(details, detail)
+	}
+
+	return details
+}
+
+func sortedPolicies(policies map[string]Policy) []Policy {
+	var names []string
+	for k := range policies {
+		names = append(names, k)
+	}
+
+	sort.Strings(names)
+
+	res := []Policy{}
+	for _, name := range names {
+		res = append(res, policies[name])
+	}
+
+	return res
+}
@@ -0,0 +1,44 @@
+package policy
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCompileError(t *testing.T) {
+	client, err := NewClient(Options{IncludeStatic: true})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	err = client.Add(Policy{
+		Name:   "custom",
+		Module: "package custom\n\nallow {\n\tinput.foo ==\n}\n",
+	})
+
+	var compileErr *CompileError
+	if !errors.As(err, &compileErr) {
+		t.Fatalf("Expected err to be a CompileError but was: %q", err)
+	}
+
+	if len(compileErr.Errors) == 0 || compileErr.Errors[0].Row != 5 {
+		t.Errorf("Expected an error on row 5 but was: %v", compileErr.Errors)
+	}
+
+	err = client.Add(Policy{
+		Name:   "custom",
+		Module: "package custom\n\nallow {\n\tdata.rule.allow\n}\n",
+	})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	modules, err := client.GetModules()
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	if modules[len(modules)-1].Path != ModulePath("custom") {
+		t.Errorf("Expected the last module to be '%s' but was: %s", ModulePath("custom"), modules[len(modules)-1].Path)
+	}
+}
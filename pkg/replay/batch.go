@@ -0,0 +1,265 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	opalogs "github.com/open-policy-agent/opa/plugins/logs"
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/xenitab/opa-bundle-api/pkg/bundle"
+	"github.com/xenitab/opa-bundle-api/pkg/rule"
+)
+
+var (
+	BaselineCurrent           = "current"
+	BaselineOriginal          = "original"
+	NullReport                = Report{}
+	ErrorBaselineNotValid     = errors.New("Baseline not valid, expected current or original")
+	ErrorResultNotBoolean     = errors.New("Result not a boolean")
+	ErrorOriginalResultMissed = errors.New("Original result missing")
+)
+
+// LogFilter selects the decision logs to replay, zero values match every log
+type LogFilter struct {
+	From   time.Time         `json:"from,omitempty"`
+	To     time.Time         `json:"to,omitempty"`
+	Path   string            `json:"path,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// Match returns true if the log is within the time window and has the path and all the labels
+func (filter LogFilter) Match(log opalogs.EventV1) bool {
+	if !filter.From.IsZero() && log.Timestamp.Before(filter.From) {
+		return false
+	}
+
+	if !filter.To.IsZero() && log.Timestamp.After(filter.To) {
+		return false
+	}
+
+	if filter.Path != "" && filter.Path != log.Path {
+		return false
+	}
+
+	for k, v := range filter.Labels {
+		if log.Labels[k] != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// BatchOptions configures a batch replay of the candidate rules
+type BatchOptions struct {
+	Rules  []rule.Rule `json:"rules"`
+	Filter LogFilter   `json:"filter"`
+	// Baseline is what the candidate rules are compared to, the current rules (default) or the original decision
+	Baseline string `json:"baseline"`
+}
+
+// BatchResult is the outcome of replaying a single decision
+type BatchResult struct {
+	DecisionID string    `json:"decision_id"`
+	Timestamp  time.Time `json:"timestamp"`
+	Baseline   bool      `json:"baseline"`
+	Replayed   bool      `json:"replayed"`
+	Changed    bool      `json:"changed"`
+	AllowedBy  []int     `json:"allowed_by,omitempty"`
+	DeniedBy   []int     `json:"denied_by,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Flips are the decisions that changed in the same direction
+type Flips struct {
+	Count       int      `json:"count"`
+	DecisionIDs []string `json:"decision_ids"`
+}
+
+func (flips *Flips) add(decisionID string) {
+	flips.Count++
+	flips.DecisionIDs = append(flips.DecisionIDs, decisionID)
+}
+
+// Report summarizes a batch replay
+type Report struct {
+	Revision         string `json:"revision"`
+	Baseline         string `json:"baseline"`
+	BaselineRevision string `json:"baseline_revision,omitempty"`
+	Total            int    `json:"total"`
+	Unchanged        int    `json:"unchanged"`
+	AllowToDeny      Flips  `json:"allow_to_deny"`
+	DenyToAllow      Flips  `json:"deny_to_allow"`
+	Failed           Flips  `json:"failed"`
+}
+
+// Add counts the result in the report
+func (report *Report) Add(result BatchResult) {
+	switch {
+	case result.Error != "":
+		report.Failed.add(result.DecisionID)
+	case !result.Changed:
+		report.Unchanged++
+	case result.Baseline:
+		report.AllowToDeny.add(result.DecisionID)
+	default:
+		report.DenyToAllow.add(result.DecisionID)
+	}
+}
+
+// Batch is a prepared batch replay, with the logs selected and the queries compiled
+type Batch struct {
+	Logs      []opalogs.EventV1
+	report    Report
+	candidate rego.PreparedEvalQuery
+	baseline  *rego.PreparedEvalQuery
+}
+
+// ReplayBatch replays all logs matching the filter against the candidate rules and reports which decisions flip
+func (client *Client) ReplayBatch(ctx context.Context, opts BatchOptions) (Report, error) {
+	batch, err := client.NewBatch(ctx, opts)
+	if err != nil {
+		return NullReport, err
+	}
+
+	report := batch.Report()
+	for _, log := range batch.Logs {
+		report.Add(batch.Replay(ctx, log))
+	}
+
+	return report, nil
+}
+
+// NewBatch selects the logs, sorted by timestamp, and prepares the queries of the candidate and the baseline
+func (client *Client) NewBatch(ctx context.Context, opts BatchOptions) (*Batch, error) {
+	baseline := opts.Baseline
+	if baseline == "" {
+		baseline = BaselineCurrent
+	}
+
+	if baseline != BaselineCurrent && baseline != BaselineOriginal {
+		return nil, ErrorBaselineNotValid
+	}
+
+	candidateContent, err := client.CandidateContent(opts.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	candidate, err := prepare(ctx, bundle.NewClient(), candidateContent)
+	if err != nil {
+		return nil, err
+	}
+
+	batch := &Batch{
+		candidate: candidate,
+		report: Report{
+			Revision:    candidateContent.Revision,
+			Baseline:    baseline,
+			AllowToDeny: Flips{DecisionIDs: []string{}},
+			DenyToAllow: Flips{DecisionIDs: []string{}},
+			Failed:      Flips{DecisionIDs: []string{}},
+		},
+	}
+
+	if baseline == BaselineCurrent {
+		currentContent, err := client.currentContent()
+		if err != nil {
+			return nil, err
+		}
+
+		query, err := prepare(ctx, client.bundleClient, currentContent)
+		if err != nil {
+			return nil, err
+		}
+
+		batch.baseline = &query
+		batch.report.BaselineRevision = currentContent.Revision
+	}
+
+	for _, log := range client.logsClient.ReadAll() {
+		if opts.Filter.Match(log) {
+			batch.Logs = append(batch.Logs, log)
+		}
+	}
+
+	sort.Slice(batch.Logs, func(i, j int) bool {
+		if batch.Logs[i].Timestamp.Equal(batch.Logs[j].Timestamp) {
+			return batch.Logs[i].DecisionID < batch.Logs[j].DecisionID
+		}
+
+		return batch.Logs[i].Timestamp.Before(batch.Logs[j].Timestamp)
+	})
+
+	batch.report.Total = len(batch.Logs)
+
+	return batch, nil
+}
+
+// Report returns an empty report for the batch, results are added with Add
+func (batch *Batch) Report() Report {
+	return batch.report
+}
+
+// Replay replays a single log, errors are part of the result so a batch can continue
+func (batch *Batch) Replay(ctx context.Context, log opalogs.EventV1) BatchResult {
+	result := BatchResult{
+		DecisionID: log.DecisionID,
+		Timestamp:  log.Timestamp,
+	}
+
+	var err error
+	if batch.baseline != nil {
+		var baseline Decision
+		baseline, err = evaluate(ctx, *batch.baseline, log.Input, ExplainOff)
+		result.Baseline = baseline.Allow
+	} else {
+		result.Baseline, err = originalAllow(log)
+	}
+
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	decision, err := evaluate(ctx, batch.candidate, log.Input, ExplainOff)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Replayed = decision.Allow
+	result.AllowedBy = decision.AllowedBy
+	result.DeniedBy = decision.DeniedBy
+	result.Changed = result.Baseline != result.Replayed
+
+	return result
+}
+
+// originalAllow returns the result that OPA logged, some clients log it as a string
+func originalAllow(log opalogs.EventV1) (bool, error) {
+	if log.Result == nil {
+		return false, ErrorOriginalResultMissed
+	}
+
+	return toBool(*log.Result)
+}
+
+func toBool(value interface{}) (bool, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return false, ErrorResultNotBoolean
+		}
+
+		return b, nil
+	}
+
+	return false, ErrorResultNotBoolean
+}
@@ -0,0 +1,98 @@
+package replay
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	opalogs "github.com/open-policy-agent/opa/plugins/logs"
+	"github.com/xenitab/opa-bundle-api/pkg/bundle"
+	"github.com/xenitab/opa-bundle-api/pkg/logs"
+	"github.com/xenitab/opa-bundle-api/pkg/policy"
+	"github.com/xenitab/opa-bundle-api/pkg/rule"
+)
+
+func TestReplayBatch(t *testing.T) {
+	client := newTestClient(t)
+
+	report, err := client.ReplayBatch(context.Background(), BatchOptions{
+		Rules: []rule.Rule{
+			{Country: "ANY", City: "ANY", Building: "ANY", Role: "guest", DeviceType: "ANY", Action: "allow"},
+		},
+		Filter: LogFilter{
+			From: time.Date(2021, 5, 2, 0, 0, 0, 0, time.UTC),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	if report.Total != 2 {
+		t.Errorf("Expected total to be 2 but was: %d", report.Total)
+	}
+
+	if !reflect.DeepEqual(report.AllowToDeny.DecisionIDs, []string{"admin"}) {
+		t.Errorf("Expected allow to deny to be [admin] but was: %v", report.AllowToDeny.DecisionIDs)
+	}
+
+	if !reflect.DeepEqual(report.DenyToAllow.DecisionIDs, []string{"guest"}) {
+		t.Errorf("Expected deny to allow to be [guest] but was: %v", report.DenyToAllow.DecisionIDs)
+	}
+}
+
+func newTestClient(t *testing.T) *Client {
+	t.Helper()
+
+	ruleClient := rule.NewClient()
+	_, err := ruleClient.Add(rule.Options{Country: "ANY", City: "ANY", Building: "ANY", Role: "admin", DeviceType: "ANY", Action: rule.ActionAllow})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	policyClient, err := policy.NewClient(policy.Options{IncludeStatic: true})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	logsClient, err := logs.NewClient(logs.Options{})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	var events []opalogs.EventV1
+	for i, role := range []string{"old", "admin", "guest"} {
+		var input interface{} = map[string]interface{}{
+			"country":     "Sweden",
+			"city":        "Gothenburg",
+			"building":    "HQ",
+			"role":        role,
+			"device_type": "Printer",
+		}
+
+		var result interface{} = role == "admin"
+
+		events = append(events, opalogs.EventV1{
+			DecisionID: role,
+			Path:       "rule/allow",
+			Input:      &input,
+			Result:     &result,
+			Timestamp:  time.Date(2021, 5, i+1, 0, 0, 0, 0, time.UTC),
+			Bundles: map[string]opalogs.BundleInfoV1{
+				"api": {Revision: "original"},
+			},
+		})
+	}
+
+	err = logsClient.CreateMultiple(events)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	return NewClient(Options{
+		RuleClient:   ruleClient,
+		BundleClient: bundle.NewClient(),
+		LogsClient:   logsClient,
+		PolicyClient: policyClient,
+	})
+}
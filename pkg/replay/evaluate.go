@@ -0,0 +1,213 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/open-policy-agent/opa/topdown"
+	"github.com/open-policy-agent/opa/topdown/lineage"
+	"github.com/xenitab/opa-bundle-api/pkg/bundle"
+)
+
+var (
+	ExplainOff            = ""
+	ExplainFull           = "full"
+	ExplainNotes          = "notes"
+	ExplainFails          = "fails"
+	NullDecision          = Decision{}
+	ErrorExplainNotValid  = errors.New("Explain not valid, expected full, notes or fails")
+	ErrorRuleIDNotNumeric = errors.New("Rule ID not numeric")
+	// decisionQuery maps the indexes of the matching rules to their IDs, comprehensions are never undefined so custom policies without the match rules still work
+	decisionQuery = strings.Join([]string{
+		`allow = data.rule.allow`,
+		`allowed_by = {id | data.rule.match_id_allow[i]; id = data.rules[i].id}`,
+		`denied_by = {id | data.rule.match_id_deny[i]; id = data.rules[i].id}`,
+	}, "; ")
+)
+
+// Decision is the result of evaluating an input, with the IDs of the rules that allowed and denied it
+type Decision struct {
+	Allow       bool     `json:"allow"`
+	AllowedBy   []int    `json:"allowed_by"`
+	DeniedBy    []int    `json:"denied_by"`
+	Explanation []string `json:"explanation,omitempty"`
+}
+
+// ValidExplain returns true for the supported explain modes, the same as the OPA REST API
+func ValidExplain(explain string) bool {
+	switch explain {
+	case ExplainOff, ExplainFull, ExplainNotes, ExplainFails:
+		return true
+	}
+
+	return false
+}
+
+// prepare compiles the query once so it can be evaluated with many inputs
+func prepare(ctx context.Context, bundleClient *bundle.Client, bundleContent bundle.Content) (rego.PreparedEvalQuery, error) {
+	b, err := bundleClient.Get(bundleContent)
+	if err != nil {
+		return rego.PreparedEvalQuery{}, err
+	}
+
+	r := rego.New(
+		rego.ParsedBundle("bundle", &b),
+		rego.Query(decisionQuery),
+	)
+
+	return r.PrepareForEval(ctx)
+}
+
+// evaluate evaluates the input, with a trace filtered like the explain query parameter of the OPA REST API
+func evaluate(ctx context.Context, query rego.PreparedEvalQuery, input *interface{}, explain string) (Decision, error) {
+	if !ValidExplain(explain) {
+		return NullDecision, ErrorExplainNotValid
+	}
+
+	var value interface{}
+	if input != nil {
+		value = *input
+	}
+
+	evalOpts := []rego.EvalOption{rego.EvalInput(value)}
+
+	var tracer *topdown.BufferTracer
+	if explain != ExplainOff {
+		tracer = topdown.NewBufferTracer()
+		evalOpts = append(evalOpts, rego.EvalQueryTracer(tracer))
+	}
+
+	resultSet, err := query.Eval(ctx, evalOpts...)
+	if err != nil {
+		return NullDecision, err
+	}
+
+	decision := Decision{
+		AllowedBy: []int{},
+		DeniedBy:  []int{},
+	}
+
+	if len(resultSet) > 0 {
+		bindings := resultSet[0].Bindings
+
+		decision.Allow, err = toBool(bindings["allow"])
+		if err != nil {
+			return NullDecision, err
+		}
+
+		decision.AllowedBy, err = toIDs(bindings["allowed_by"])
+		if err != nil {
+			return NullDecision, err
+		}
+
+		decision.DeniedBy, err = toIDs(bindings["denied_by"])
+		if err != nil {
+			return NullDecision, err
+		}
+	}
+
+	if tracer != nil {
+		decision.Explanation = explanation(*tracer, explain)
+	}
+
+	return decision, nil
+}
+
+func explanation(trace []*topdown.Event, explain string) []string {
+	switch explain {
+	case ExplainNotes:
+		trace = lineage.Notes(trace)
+	case ExplainFails:
+		trace = lineage.Fails(trace)
+	}
+
+	var buf bytes.Buffer
+	topdown.PrettyTrace(&buf, trace)
+
+	lines := []string{}
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines
+}
+
+func toIDs(value interface{}) ([]int, error) {
+	values, _ := value.([]interface{})
+
+	ids := []int{}
+	for _, v := range values {
+		number, ok := v.(json.Number)
+		if !ok {
+			return nil, ErrorRuleIDNotNumeric
+		}
+
+		id, err := number.Int64()
+		if err != nil {
+			return nil, ErrorRuleIDNotNumeric
+		}
+
+		ids = append(ids, int(id))
+	}
+
+	sort.Ints(ids)
+
+	return ids, nil
+}
+
+// Evaluation is the decisions for a list of inputs and the revision they were evaluated with
+type Evaluation struct {
+	Revision  string     `json:"revision"`
+	Decisions []Decision `json:"decisions"`
+}
+
+// Evaluate evaluates the inputs with the current rules, or the rules of a stored revision if revision isn't empty
+func (client *Client) Evaluate(inputs []interface{}, revision string, explain string) (Evaluation, error) {
+	if !ValidExplain(explain) {
+		return Evaluation{}, ErrorExplainNotValid
+	}
+
+	bundleContent, err := client.evaluationContent(revision)
+	if err != nil {
+		return Evaluation{}, err
+	}
+
+	ctx := context.Background()
+
+	query, err := prepare(ctx, client.bundleClient, bundleContent)
+	if err != nil {
+		return Evaluation{}, err
+	}
+
+	evaluation := Evaluation{
+		Revision:  bundleContent.Revision,
+		Decisions: []Decision{},
+	}
+
+	for i := range inputs {
+		decision, err := evaluate(ctx, query, &inputs[i], explain)
+		if err != nil {
+			return Evaluation{}, err
+		}
+
+		evaluation.Decisions = append(evaluation.Decisions, decision)
+	}
+
+	return evaluation, nil
+}
+
+// evaluationContent returns the content of the revision, or the current content if no revision is given
+func (client *Client) evaluationContent(revision string) (bundle.Content, error) {
+	if revision == "" {
+		return client.currentContent()
+	}
+
+	return client.revisionContent(revision)
+}
@@ -0,0 +1,429 @@
+package replay
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	DefaultWorkers       = 2
+	DefaultJobLimit      = 100
+	DefaultJobMaxAge     = 24 * time.Hour
+	DefaultResultsLimit  = 100
+	MaxResultsLimit      = 1000
+	NullJob              = Job{}
+	ErrorJobNotFound     = errors.New("Job not found")
+	ErrorJobQueueFull    = errors.New("Job queue full")
+	ErrorJobIDGeneration = errors.New("Not able to generate job ID")
+)
+
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusCompleted = "completed"
+	JobStatusCanceled  = "canceled"
+)
+
+// JobOptions configures the worker pool running the replay jobs
+type JobOptions struct {
+	ReplayClient *Client
+	// Workers is the amount of jobs running at the same time
+	Workers int
+	// Limit is the amount of jobs kept, queued and running jobs included. The oldest finished jobs are removed first
+	Limit int
+	// MaxAge is how long finished jobs are kept
+	MaxAge time.Duration
+}
+
+// Job is the progress of an asynchronous batch replay, the report is updated while the job runs
+type Job struct {
+	ID        string     `json:"id"`
+	Status    string     `json:"status"`
+	Created   time.Time  `json:"created"`
+	Started   *time.Time `json:"started,omitempty"`
+	Finished  *time.Time `json:"finished,omitempty"`
+	Processed int        `json:"processed"`
+	Progress  float64    `json:"progress"`
+	ETA       *time.Time `json:"eta,omitempty"`
+	Report    Report     `json:"report"`
+}
+
+// Results is a page of the results of a job
+type Results struct {
+	Total   int           `json:"total"`
+	Offset  int           `json:"offset"`
+	Limit   int           `json:"limit"`
+	Results []BatchResult `json:"results"`
+}
+
+type job struct {
+	Job
+	batch   *Batch
+	results []BatchResult
+	cancel  context.CancelFunc
+	ctx     context.Context
+}
+
+type JobClient struct {
+	sync.RWMutex
+	replayClient *Client
+	jobs         map[string]*job
+	// queue is the queued jobs in order, the workers wait on queued for a job to be added
+	queue  []*job
+	queued *sync.Cond
+	limit  int
+	maxAge time.Duration
+	closed bool
+}
+
+// NewJobClient returns a JobClient with the workers running in the background
+func NewJobClient(opts JobOptions) *JobClient {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultJobLimit
+	}
+
+	maxAge := opts.MaxAge
+	if maxAge <= 0 {
+		maxAge = DefaultJobMaxAge
+	}
+
+	client := &JobClient{
+		replayClient: opts.ReplayClient,
+		jobs:         make(map[string]*job),
+		limit:        limit,
+		maxAge:       maxAge,
+	}
+
+	client.queued = sync.NewCond(&client.RWMutex)
+
+	for i := 0; i < workers; i++ {
+		go client.runWorker()
+	}
+
+	return client
+}
+
+// Close stops the workers, running jobs are canceled
+func (client *JobClient) Close() {
+	client.Lock()
+	defer client.Unlock()
+
+	client.closed = true
+	client.queued.Broadcast()
+
+	for _, j := range client.jobs {
+		j.cancel()
+	}
+}
+
+// Create selects the logs and prepares the queries before the job is queued, so invalid options are returned directly
+func (client *JobClient) Create(opts BatchOptions) (Job, error) {
+	batch, err := client.replayClient.NewBatch(context.Background(), opts)
+	if err != nil {
+		return NullJob, err
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		return NullJob, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		Job: Job{
+			ID:      id,
+			Status:  JobStatusQueued,
+			Created: time.Now().UTC(),
+			Report:  batch.Report(),
+		},
+		batch:  batch,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	client.Lock()
+	defer client.Unlock()
+
+	client.removeFinishedWithoutLock()
+
+	if len(client.jobs) >= client.limit {
+		cancel()
+		return NullJob, ErrorJobQueueFull
+	}
+
+	client.jobs[id] = j
+	client.queue = append(client.queue, j)
+	client.queued.Signal()
+
+	return j.snapshot(), nil
+}
+
+func (client *JobClient) Get(id string) (Job, error) {
+	client.RLock()
+	defer client.RUnlock()
+
+	j, found := client.jobs[id]
+	if !found {
+		return NullJob, ErrorJobNotFound
+	}
+
+	return j.snapshot(), nil
+}
+
+// GetAll returns all jobs, newest first
+func (client *JobClient) GetAll() []Job {
+	client.RLock()
+	defer client.RUnlock()
+
+	res := []Job{}
+	for _, j := range client.jobs {
+		res = append(res, j.snapshot())
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Created.After(res[j].Created)
+	})
+
+	return res
+}
+
+// GetResults returns a page of the results, only the changed decisions if onlyChanged is true
+func (client *JobClient) GetResults(id string, offset int, limit int, onlyChanged bool) (Results, error) {
+	client.RLock()
+	defer client.RUnlock()
+
+	j, found := client.jobs[id]
+	if !found {
+		return Results{}, ErrorJobNotFound
+	}
+
+	if limit <= 0 {
+		limit = DefaultResultsLimit
+	}
+
+	if limit > MaxResultsLimit {
+		limit = MaxResultsLimit
+	}
+
+	if offset < 0 {
+		offset = 0
+	}
+
+	results := j.results
+	if onlyChanged {
+		results = []BatchResult{}
+		for _, result := range j.results {
+			if result.Changed {
+				results = append(results, result)
+			}
+		}
+	}
+
+	page := Results{
+		Total:   len(results),
+		Offset:  offset,
+		Limit:   limit,
+		Results: []BatchResult{},
+	}
+
+	if offset < len(results) {
+		end := offset + limit
+		if end > len(results) {
+			end = len(results)
+		}
+
+		page.Results = append(page.Results, results[offset:end]...)
+	}
+
+	return page, nil
+}
+
+// Delete cancels a queued or running job and removes a finished job
+func (client *JobClient) Delete(id string) (Job, error) {
+	client.Lock()
+	defer client.Unlock()
+
+	j, found := client.jobs[id]
+	if !found {
+		return NullJob, ErrorJobNotFound
+	}
+
+	if j.Finished != nil {
+		delete(client.jobs, id)
+		return j.snapshot(), nil
+	}
+
+	j.cancel()
+
+	// a queued job is finished and removed from the queue directly, a running job when the worker notices
+	if j.Status == JobStatusQueued {
+		client.removeQueuedWithoutLock(j)
+		client.finishWithoutLock(j, JobStatusCanceled)
+	}
+
+	return j.snapshot(), nil
+}
+
+func (client *JobClient) runWorker() {
+	for {
+		j := client.next()
+		if j == nil {
+			return
+		}
+
+		client.run(j)
+	}
+}
+
+// next waits for a queued job and marks it as running, nil is returned when the client is closed
+func (client *JobClient) next() *job {
+	client.Lock()
+	defer client.Unlock()
+
+	for len(client.queue) == 0 && !client.closed {
+		client.queued.Wait()
+	}
+
+	if client.closed {
+		return nil
+	}
+
+	j := client.queue[0]
+	client.queue = client.queue[1:]
+
+	started := time.Now().UTC()
+	j.Started = &started
+	j.Status = JobStatusRunning
+
+	return j
+}
+
+func (client *JobClient) run(j *job) {
+	batch := j.batch
+
+	for _, log := range batch.Logs {
+		if j.ctx.Err() != nil {
+			break
+		}
+
+		result := batch.Replay(j.ctx, log)
+
+		client.Lock()
+		j.results = append(j.results, result)
+		j.Report.Add(result)
+		j.Processed++
+		client.Unlock()
+	}
+
+	client.Lock()
+	defer client.Unlock()
+
+	if j.ctx.Err() != nil {
+		client.finishWithoutLock(j, JobStatusCanceled)
+		return
+	}
+
+	client.finishWithoutLock(j, JobStatusCompleted)
+}
+
+func (client *JobClient) removeQueuedWithoutLock(j *job) {
+	for i, queued := range client.queue {
+		if queued == j {
+			client.queue = append(client.queue[:i:i], client.queue[i+1:]...)
+			return
+		}
+	}
+}
+
+// removeFinishedWithoutLock makes room for a new job by removing the oldest finished jobs
+func (client *JobClient) removeFinishedWithoutLock() {
+	if len(client.jobs) < client.limit {
+		return
+	}
+
+	var finished []*job
+	for _, j := range client.jobs {
+		if j.Finished != nil {
+			finished = append(finished, j)
+		}
+	}
+
+	sort.Slice(finished, func(a, b int) bool {
+		return finished[a].Finished.Before(*finished[b].Finished)
+	})
+
+	for _, j := range finished {
+		if len(client.jobs) < client.limit {
+			return
+		}
+
+		delete(client.jobs, j.ID)
+	}
+}
+
+// finishWithoutLock drops the logs and queries of the batch, the report and the results are kept until the job is older than the max age
+func (client *JobClient) finishWithoutLock(j *job, status string) {
+	finished := time.Now().UTC()
+	j.Finished = &finished
+	j.Status = status
+	j.batch = nil
+	j.cancel()
+
+	time.AfterFunc(client.maxAge, func() {
+		client.Lock()
+		defer client.Unlock()
+
+		if client.jobs[j.ID] == j {
+			delete(client.jobs, j.ID)
+		}
+	})
+}
+
+// snapshot returns a copy of the job with the progress and ETA calculated
+func (j *job) snapshot() Job {
+	res := j.Job
+	res.Report.AllowToDeny.DecisionIDs = append([]string{}, j.Report.AllowToDeny.DecisionIDs...)
+	res.Report.DenyToAllow.DecisionIDs = append([]string{}, j.Report.DenyToAllow.DecisionIDs...)
+	res.Report.Failed.DecisionIDs = append([]string{}, j.Report.Failed.DecisionIDs...)
+
+	total := j.Report.Total
+	if total == 0 {
+		if j.Finished != nil {
+			res.Progress = 1
+		}
+
+		return res
+	}
+
+	res.Progress = float64(j.Processed) / float64(total)
+
+	if j.Status == JobStatusRunning && j.Processed > 0 {
+		elapsed := time.Since(*j.Started)
+		remaining := time.Duration(float64(elapsed) / float64(j.Processed) * float64(total-j.Processed))
+		eta := time.Now().UTC().Add(remaining)
+		res.ETA = &eta
+	}
+
+	return res
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", ErrorJobIDGeneration
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,145 @@
+package replay
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/xenitab/opa-bundle-api/pkg/rule"
+)
+
+func TestJob(t *testing.T) {
+	jobClient := NewJobClient(JobOptions{
+		ReplayClient: newTestClient(t),
+	})
+
+	defer jobClient.Close()
+
+	job, err := jobClient.Create(BatchOptions{
+		Rules: []rule.Rule{
+			{Country: "ANY", City: "ANY", Building: "ANY", Role: "guest", DeviceType: "ANY", Action: "allow"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for job.Status != JobStatusCompleted && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+
+		job, err = jobClient.Get(job.ID)
+		if err != nil {
+			t.Fatalf("Expected err to be nil: %q", err)
+		}
+	}
+
+	if job.Status != JobStatusCompleted || job.Progress != 1 {
+		t.Fatalf("Expected job to be completed but was: %s (%f)", job.Status, job.Progress)
+	}
+
+	jobClient.RLock()
+	batch := jobClient.jobs[job.ID].batch
+	jobClient.RUnlock()
+
+	if batch != nil {
+		t.Errorf("Expected the logs of the batch to be dropped when the job finished")
+	}
+
+	results, err := jobClient.GetResults(job.ID, 1, 1, false)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	if results.Total != 3 || len(results.Results) != 1 || results.Results[0].DecisionID != "admin" {
+		t.Errorf("Expected the second of 3 results to be admin but was: %v", results)
+	}
+
+	results, err = jobClient.GetResults(job.ID, 0, 0, true)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	if results.Total != 2 {
+		t.Errorf("Expected 2 changed results but was: %d", results.Total)
+	}
+}
+
+func TestJobQueue(t *testing.T) {
+	jobClient := NewJobClient(JobOptions{
+		ReplayClient: newTestClient(t),
+		Limit:        2,
+	})
+
+	// without workers the jobs stay queued
+	jobClient.Close()
+
+	opts := BatchOptions{
+		Rules: []rule.Rule{
+			{Country: "ANY", City: "ANY", Building: "ANY", Role: "guest", DeviceType: "ANY", Action: "allow"},
+		},
+	}
+
+	first, err := jobClient.Create(opts)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	_, err = jobClient.Create(opts)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	_, err = jobClient.Create(opts)
+	if !errors.Is(err, ErrorJobQueueFull) {
+		t.Fatalf("Expected the queue to be full: %q", err)
+	}
+
+	canceled, err := jobClient.Delete(first.ID)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	if canceled.Status != JobStatusCanceled {
+		t.Errorf("Expected the queued job to be canceled but was: %s", canceled.Status)
+	}
+
+	_, err = jobClient.Create(opts)
+	if err != nil {
+		t.Fatalf("Expected the canceled job to make room: %q", err)
+	}
+
+	if len(jobClient.queue) != 2 || len(jobClient.GetAll()) != 2 {
+		t.Errorf("Expected 2 queued jobs but was: %d (%d jobs)", len(jobClient.queue), len(jobClient.GetAll()))
+	}
+}
+
+func TestJobMaxAge(t *testing.T) {
+	jobClient := NewJobClient(JobOptions{
+		ReplayClient: newTestClient(t),
+		MaxAge:       50 * time.Millisecond,
+	})
+
+	defer jobClient.Close()
+
+	job, err := jobClient.Create(BatchOptions{
+		Rules: []rule.Rule{
+			{Country: "ANY", City: "ANY", Building: "ANY", Role: "guest", DeviceType: "ANY", Action: "allow"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		_, err = jobClient.Get(job.ID)
+		if errors.Is(err, ErrorJobNotFound) {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Errorf("Expected the finished job to be removed after the max age")
+}
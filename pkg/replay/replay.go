@@ -2,74 +2,288 @@ package replay
 
 import (
 	"context"
+	"errors"
+	"sort"
 
-	"github.com/open-policy-agent/opa/rego"
+	opalogs "github.com/open-policy-agent/opa/plugins/logs"
 	"github.com/xenitab/opa-bundle-api/pkg/bundle"
+	"github.com/xenitab/opa-bundle-api/pkg/definition"
 	"github.com/xenitab/opa-bundle-api/pkg/logs"
+	"github.com/xenitab/opa-bundle-api/pkg/policy"
 	"github.com/xenitab/opa-bundle-api/pkg/rule"
 	"github.com/xenitab/opa-bundle-api/pkg/util"
 )
 
 var (
-	NullOpaResultSet = rego.ResultSet{}
+	NullResult = Result{}
+	// RevisionOriginal replays with the revision the decision was made with
+	RevisionOriginal                = "original"
+	ErrorOriginalRevisionMissing    = errors.New("Original revision missing from the decision log")
+	ErrorRevisionPoliciesNotMatched = errors.New("Policies of the revision not available, only the rules are stored in the history")
 )
 
 type Options struct {
 	RuleClient   *rule.Client
 	BundleClient *bundle.Client
 	LogsClient   *logs.Client
+	PolicyClient *policy.Client
+	// DefinitionClient resolves the revisions of named bundles, without it only revisions of the default bundle can be replayed
+	DefinitionClient *definition.Client
 }
 
 type Client struct {
-	bundleClient *bundle.Client
-	logsClient   *logs.Client
-	ruleClient   *rule.Client
+	bundleClient     *bundle.Client
+	logsClient       *logs.Client
+	ruleClient       *rule.Client
+	policyClient     *policy.Client
+	definitionClient *definition.Client
 }
 
 func NewClient(opts Options) *Client {
 	return &Client{
-		ruleClient:   opts.RuleClient,
-		bundleClient: opts.BundleClient,
-		logsClient:   opts.LogsClient,
+		ruleClient:       opts.RuleClient,
+		bundleClient:     opts.BundleClient,
+		logsClient:       opts.LogsClient,
+		policyClient:     opts.PolicyClient,
+		definitionClient: opts.DefinitionClient,
 	}
 }
 
-func (client *Client) ReplayLog(decisionID string) (rego.ResultSet, error) {
-	log, err := client.logsClient.Read(decisionID)
+// Result compares the replayed decision with the decision OPA logged
+type Result struct {
+	DecisionID       string       `json:"decision_id"`
+	Original         *interface{} `json:"original"`
+	OriginalRevision string       `json:"original_revision"`
+	Replayed         bool         `json:"replayed"`
+	Revision         string       `json:"revision"`
+	Changed          bool         `json:"changed"`
+	AllowedBy        []int        `json:"allowed_by"`
+	DeniedBy         []int        `json:"denied_by"`
+	Explanation      []string     `json:"explanation,omitempty"`
+}
+
+// ReplayLog replays the decision with the current rules, explain adds a trace like the explain query parameter of OPA
+func (client *Client) ReplayLog(decisionID string, explain string) (Result, error) {
+	bundleContent, err := client.currentContent()
 	if err != nil {
-		return NullOpaResultSet, err
+		return NullResult, err
 	}
 
-	input := *log.Input
+	return client.replayLog(decisionID, client.bundleClient, bundleContent, explain)
+}
+
+// ReplayLogWithRevision replays the decision with the rules of a stored revision, or the revision the decision was made with using RevisionOriginal
+func (client *Client) ReplayLogWithRevision(decisionID string, revision string, explain string) (Result, error) {
+	if revision == RevisionOriginal {
+		log, err := client.logsClient.Read(decisionID)
+		if err != nil {
+			return NullResult, err
+		}
 
-	data, err := client.ruleClient.GetAllJSON()
+		revision = originalRevision(log)
+		if revision == "" {
+			return NullResult, ErrorOriginalRevisionMissing
+		}
+	}
+
+	bundleContent, err := client.revisionContent(revision)
 	if err != nil {
-		return NullOpaResultSet, err
+		return NullResult, err
 	}
 
-	dataBytes := []byte(data)
-	revision, err := util.BytesToHash(dataBytes)
+	return client.replayLog(decisionID, client.bundleClient, bundleContent, explain)
+}
+
+// ReplayLogWithRules replays the decision with the rules instead of the current rules
+func (client *Client) ReplayLogWithRules(decisionID string, rules []rule.Rule, explain string) (Result, error) {
+	bundleContent, err := client.CandidateContent(rules)
 	if err != nil {
-		return NullOpaResultSet, err
+		return NullResult, err
 	}
 
-	bundle, err := client.bundleClient.Get(dataBytes, revision)
+	// the candidate bundle shouldn't push the served bundles out of the cache
+	return client.replayLog(decisionID, bundle.NewClient(), bundleContent, explain)
+}
+
+func (client *Client) replayLog(decisionID string, bundleClient *bundle.Client, bundleContent bundle.Content, explain string) (Result, error) {
+	if !ValidExplain(explain) {
+		return NullResult, ErrorExplainNotValid
+	}
+
+	log, err := client.logsClient.Read(decisionID)
 	if err != nil {
-		return NullOpaResultSet, err
+		return NullResult, err
 	}
 
 	ctx := context.Background()
 
-	rego := rego.New(
-		rego.ParsedBundle("bundle", &bundle),
-		rego.Input(input),
-		rego.Query(`data.rule.allow`),
-	)
+	query, err := prepare(ctx, bundleClient, bundleContent)
+	if err != nil {
+		return NullResult, err
+	}
+
+	decision, err := evaluate(ctx, query, log.Input, explain)
+	if err != nil {
+		return NullResult, err
+	}
+
+	return Result{
+		DecisionID:       log.DecisionID,
+		Original:         log.Result,
+		OriginalRevision: originalRevision(log),
+		Replayed:         decision.Allow,
+		Revision:         bundleContent.Revision,
+		Changed:          changed(log, decision.Allow),
+		AllowedBy:        decision.AllowedBy,
+		DeniedBy:         decision.DeniedBy,
+		Explanation:      decision.Explanation,
+	}, nil
+}
+
+// originalRevision returns the revision of the bundle used for the decision, the first bundle by name if there are many
+func originalRevision(log opalogs.EventV1) string {
+	var names []string
+	for name := range log.Bundles {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		if log.Bundles[name].Revision != "" {
+			return log.Bundles[name].Revision
+		}
+	}
+
+	return log.Revision
+}
+
+// changed returns true if the replayed decision differs from the logged one, which counts as changed if it isn't a boolean
+func changed(log opalogs.EventV1, replayed bool) bool {
+	original, err := originalAllow(log)
+	if err != nil {
+		return true
+	}
+
+	return original != replayed
+}
+
+// CandidateContent returns the bundle content with the rules instead of the current rules, the current policies are still used.
+// The rules keep their IDs so that allowed_by and denied_by refer to the posted rules, rules without an ID get a new one
+func (client *Client) CandidateContent(rules []rule.Rule) (bundle.Content, error) {
+	tmpRuleClient := rule.NewClient()
+
+	err := tmpRuleClient.Replace(rules, rule.NullAuthor)
+	if err != nil {
+		return bundle.NullContent, err
+	}
+
+	return client.content(tmpRuleClient)
+}
+
+// revisionContent returns the bundle content of a revision in the rule history, the policies have no history so they have to be the current ones
+func (client *Client) revisionContent(revision string) (bundle.Content, error) {
+	ruleRevision, _ := bundle.SplitRevision(revision)
+
+	modules, err := client.policyClient.GetModules()
+	if err != nil {
+		return bundle.NullContent, err
+	}
+
+	data, err := client.ruleClient.GetRevisionJSON(ruleRevision)
+	if err != nil && !errors.Is(err, rule.ErrorRevisionNotFound) {
+		return bundle.NullContent, err
+	}
+
+	if err == nil {
+		bundleContent, err := bundle.NewContent([]byte(data), modules)
+		if err != nil {
+			return bundle.NullContent, err
+		}
+
+		if bundleContent.Revision == revision {
+			return bundleContent, nil
+		}
+	}
+
+	// the revision of a named bundle is the hash of the filtered rules, which can be the same as another rule revision
+	bundleContent, namedErr := client.definitionRevisionContent(revision, modules)
+	if namedErr == nil {
+		return bundleContent, nil
+	}
+
+	if err == nil {
+		return bundle.NullContent, ErrorRevisionPoliciesNotMatched
+	}
+
+	return bundle.NullContent, namedErr
+}
+
+// definitionRevisionContent returns the content of a named bundle at a revision in the rule history, only the current definitions are known
+// so the filter and roots of the named bundle can't have changed since the revision
+func (client *Client) definitionRevisionContent(revision string, modules []bundle.Module) (bundle.Content, error) {
+	if client.definitionClient == nil {
+		return bundle.NullContent, rule.ErrorRevisionNotFound
+	}
+
+	dataRevision, modulesRevision := bundle.SplitRevision(revision)
+	dataMatched := false
+	summaries := client.ruleClient.GetRevisions()
+
+	for _, d := range client.definitionClient.GetAll() {
+		// the modules revision only depends on the roots, so only the data is hashed for every revision
+		emptyContent, err := bundle.NewContentWithRoots(nil, modules, d.Roots)
+		if err != nil {
+			return bundle.NullContent, err
+		}
+
+		_, definitionModulesRevision := bundle.SplitRevision(emptyContent.Revision)
+
+		for _, summary := range summaries {
+			data, err := client.ruleClient.GetRevisionFilteredJSON(summary.Revision, d.Filter)
+			if err != nil {
+				return bundle.NullContent, err
+			}
+
+			hash, err := util.BytesToHash([]byte(data))
+			if err != nil {
+				return bundle.NullContent, err
+			}
+
+			if hash != dataRevision {
+				continue
+			}
+
+			if definitionModulesRevision != modulesRevision {
+				dataMatched = true
+				continue
+			}
+
+			return bundle.NewContentWithRoots([]byte(data), modules, d.Roots)
+		}
+	}
+
+	if dataMatched {
+		return bundle.NullContent, ErrorRevisionPoliciesNotMatched
+	}
+
+	return bundle.NullContent, rule.ErrorRevisionNotFound
+}
+
+func (client *Client) currentContent() (bundle.Content, error) {
+	return client.content(client.ruleClient)
+}
+
+func (client *Client) content(ruleClient *rule.Client) (bundle.Content, error) {
+	data, err := ruleClient.GetAllJSON()
+	if err != nil {
+		return bundle.NullContent, err
+	}
 
-	resultSet, err := rego.Eval(ctx)
+	modules, err := client.policyClient.GetModules()
 	if err != nil {
-		return NullOpaResultSet, err
+		return bundle.NullContent, err
 	}
 
-	return resultSet, nil
+	return bundle.NewContent([]byte(data), modules)
 }
@@ -0,0 +1,161 @@
+package replay
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/xenitab/opa-bundle-api/pkg/bundle"
+	"github.com/xenitab/opa-bundle-api/pkg/definition"
+	"github.com/xenitab/opa-bundle-api/pkg/rule"
+)
+
+func TestReplayLog(t *testing.T) {
+	client := newTestClient(t)
+
+	result, err := client.ReplayLog("admin", ExplainOff)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	if !result.Replayed || result.Changed || result.OriginalRevision != "original" {
+		t.Errorf("Expected admin to be allowed and unchanged at revision 'original' but was: %v", result)
+	}
+
+	if !reflect.DeepEqual(result.AllowedBy, []int{1}) || len(result.DeniedBy) != 0 {
+		t.Errorf("Expected admin to be allowed by rule 1 and denied by none but was: %v and %v", result.AllowedBy, result.DeniedBy)
+	}
+
+	result, err = client.ReplayLog("guest", ExplainFails)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	if result.Replayed || len(result.Explanation) == 0 {
+		t.Errorf("Expected guest to be denied with an explanation but was: %v", result)
+	}
+
+	_, err = client.ReplayLog("guest", "everything")
+	if !errors.Is(err, ErrorExplainNotValid) {
+		t.Errorf("Expected err to be '%s' but was: %v", ErrorExplainNotValid, err)
+	}
+
+	result, err = client.ReplayLogWithRules("admin", []rule.Rule{}, ExplainOff)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	if result.Replayed || !result.Changed {
+		t.Errorf("Expected admin to be denied and changed without rules but was: %v", result)
+	}
+
+	rules := []rule.Rule{
+		{ID: 42, Country: "ANY", City: "ANY", Building: "ANY", Role: "admin", DeviceType: "ANY", Action: "allow"},
+	}
+
+	result, err = client.ReplayLogWithRules("admin", rules, ExplainOff)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	if !reflect.DeepEqual(result.AllowedBy, []int{42}) {
+		t.Errorf("Expected admin to be allowed by the posted rule 42 but was: %v", result.AllowedBy)
+	}
+}
+
+func TestReplayLogWithRevision(t *testing.T) {
+	client := newTestClient(t)
+	revision, err := client.ruleClient.Revision()
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	err = client.ruleClient.Delete(1, rule.NullAuthor, "")
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	result, err := client.ReplayLogWithRevision("admin", revision, ExplainOff)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	if !result.Replayed || result.Revision != revision {
+		t.Errorf("Expected admin to be allowed with revision '%s' but was: %v", revision, result)
+	}
+
+	_, err = client.ReplayLogWithRevision("admin", RevisionOriginal, ExplainOff)
+	if !errors.Is(err, rule.ErrorRevisionNotFound) {
+		t.Errorf("Expected err to be '%s' but was: %v", rule.ErrorRevisionNotFound, err)
+	}
+}
+
+func TestReplayLogWithNamedBundleRevision(t *testing.T) {
+	client := newTestClient(t)
+
+	definitionClient, err := definition.NewClient(definition.Options{})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	named := definition.Definition{
+		Name:   "admins",
+		Filter: rule.Filter{Role: []string{"admin"}},
+		Roots:  []string{"rule", "rules"},
+	}
+
+	err = definitionClient.Add(named)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	client.definitionClient = definitionClient
+
+	_, err = client.ruleClient.Add(rule.Options{Country: "ANY", City: "ANY", Building: "ANY", Role: "guest", DeviceType: "ANY", Action: rule.ActionDeny})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	data, err := client.ruleClient.GetFilteredJSON(named.Filter)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	modules, err := client.policyClient.GetModules()
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	namedContent, err := bundle.NewContentWithRoots([]byte(data), modules, named.Roots)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	// the guest rule isn't part of the named bundle, so only the admin rule can match
+	result, err := client.ReplayLogWithRevision("guest", namedContent.Revision, ExplainOff)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	if result.Revision != namedContent.Revision || result.Replayed || len(result.DeniedBy) != 0 {
+		t.Errorf("Expected guest to be denied by default with the named bundle revision '%s' but was: %v", namedContent.Revision, result)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	client := newTestClient(t)
+
+	inputs := []interface{}{
+		map[string]interface{}{"country": "Sweden", "city": "Gothenburg", "building": "HQ", "role": "admin", "device_type": "Alarm"},
+		map[string]interface{}{"country": "Sweden", "city": "Gothenburg", "building": "HQ", "role": "guest", "device_type": "Alarm"},
+	}
+
+	evaluation, err := client.Evaluate(inputs, "", ExplainOff)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	if len(evaluation.Decisions) != 2 || !evaluation.Decisions[0].Allow || evaluation.Decisions[1].Allow {
+		t.Errorf("Expected admin to be allowed and guest to be denied but was: %v", evaluation.Decisions)
+	}
+}
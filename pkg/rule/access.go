@@ -0,0 +1,196 @@
+package rule
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var (
+	// MaxAccessCombinations limits how many combinations are evaluated for a single query
+	MaxAccessCombinations   = 100000
+	ErrorAccessQueryTooWide = errors.New("Access query too wide, add more attributes")
+)
+
+const attributeCount = 5
+
+// AccessQuery selects the inputs to compute the access for, empty fields can be any value
+type AccessQuery struct {
+	Country    string `json:"country,omitempty"`
+	City       string `json:"city,omitempty"`
+	Building   string `json:"building,omitempty"`
+	Role       string `json:"role,omitempty"`
+	DeviceType string `json:"device_type,omitempty"`
+}
+
+// Combination is a set of inputs with the same decision, ANY means every value not listed in another combination
+type Combination struct {
+	Country    string `json:"country"`
+	City       string `json:"city"`
+	Building   string `json:"building"`
+	Role       string `json:"role"`
+	DeviceType string `json:"device_type"`
+	AllowedBy  []ID   `json:"allowed_by"`
+	DeniedBy   []ID   `json:"denied_by"`
+}
+
+// Access is the effective access of the inputs matching the query, inputs not in any combination are denied by default
+type Access struct {
+	Query   AccessQuery   `json:"query"`
+	Allowed []Combination `json:"allowed"`
+	Denied  []Combination `json:"denied"`
+}
+
+// Access computes what the inputs matching the query can access, with the same wildcard and deny-overrides semantics as rule.rego
+func (client *Client) Access(query AccessQuery) (Access, error) {
+	client.RLock()
+	defer client.RUnlock()
+
+	return access(sortedRules(client.rules), query)
+}
+
+func access(rules []Rule, query AccessQuery) (Access, error) {
+	queryValues := [attributeCount]string{query.Country, query.City, query.Building, query.Role, query.DeviceType}
+
+	// only the rules that can match an input selected by the query are relevant
+	var relevant []Rule
+	for _, rule := range rules {
+		if matchAttributes(attributes(rule), queryValues, true) {
+			relevant = append(relevant, rule)
+		}
+	}
+
+	// every value mentioned by a rule is a combination of its own, ANY covers all the other values
+	var candidates [attributeCount][]string
+	combinations := 1
+	for i := range candidates {
+		if queryValues[i] != "" {
+			candidates[i] = []string{queryValues[i]}
+			continue
+		}
+
+		values := map[string]bool{WildcardString: true}
+		for _, rule := range relevant {
+			values[attributes(rule)[i]] = true
+		}
+
+		for value := range values {
+			candidates[i] = append(candidates[i], value)
+		}
+
+		sort.Strings(candidates[i])
+
+		combinations *= len(candidates[i])
+		if combinations > MaxAccessCombinations {
+			return Access{}, ErrorAccessQueryTooWide
+		}
+	}
+
+	res := Access{
+		Query:   query,
+		Allowed: []Combination{},
+		Denied:  []Combination{},
+	}
+
+	var input [attributeCount]string
+	var walk func(i int)
+	walk = func(i int) {
+		if i < attributeCount {
+			for _, value := range candidates[i] {
+				input[i] = value
+				walk(i + 1)
+			}
+
+			return
+		}
+
+		combination := Combination{
+			Country:    input[0],
+			City:       input[1],
+			Building:   input[2],
+			Role:       input[3],
+			DeviceType: input[4],
+			AllowedBy:  []ID{},
+			DeniedBy:   []ID{},
+		}
+
+		for _, rule := range relevant {
+			if !matchAttributes(attributes(rule), input, false) {
+				continue
+			}
+
+			if ToAction(rule.Action) == ActionDeny {
+				combination.DeniedBy = append(combination.DeniedBy, rule.ID)
+			} else {
+				combination.AllowedBy = append(combination.AllowedBy, rule.ID)
+			}
+		}
+
+		switch {
+		case len(combination.DeniedBy) > 0:
+			res.Denied = append(res.Denied, combination)
+		case len(combination.AllowedBy) > 0:
+			res.Allowed = append(res.Allowed, combination)
+		}
+	}
+
+	walk(0)
+
+	res.Allowed = withoutCovered(res.Allowed)
+	res.Denied = withoutCovered(res.Denied)
+
+	return res, nil
+}
+
+// withoutCovered drops the combinations that a more general combination matched by the same rules already describes
+func withoutCovered(combinations []Combination) []Combination {
+	keys := make(map[string]bool, len(combinations))
+	for _, combination := range combinations {
+		keys[combinationKey(combination, 0)] = true
+	}
+
+	res := []Combination{}
+	for _, combination := range combinations {
+		covered := false
+		for mask := 1; mask < 1<<attributeCount && !covered; mask++ {
+			covered = combinationKey(combination, mask) != combinationKey(combination, 0) && keys[combinationKey(combination, mask)]
+		}
+
+		if !covered {
+			res = append(res, combination)
+		}
+	}
+
+	return res
+}
+
+// combinationKey identifies the combination with the attributes in mask replaced by the wildcard
+func combinationKey(combination Combination, mask int) string {
+	values := [attributeCount]string{combination.Country, combination.City, combination.Building, combination.Role, combination.DeviceType}
+	for i := range values {
+		if mask&(1<<i) != 0 {
+			values[i] = WildcardString
+		}
+	}
+
+	return fmt.Sprintf("%q %v %v", values, combination.AllowedBy, combination.DeniedBy)
+}
+
+// matchAttributes returns true if the rule matches the input, empty input values match everything if emptyMatches is true
+func matchAttributes(rule [attributeCount]string, input [attributeCount]string, emptyMatches bool) bool {
+	for i := range rule {
+		if emptyMatches && input[i] == "" {
+			continue
+		}
+
+		if rule[i] != WildcardString && rule[i] != input[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func attributes(rule Rule) [attributeCount]string {
+	return [attributeCount]string{rule.Country, rule.City, rule.Building, rule.Role, rule.DeviceType}
+}
@@ -0,0 +1,54 @@
+package rule
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAccess(t *testing.T) {
+	rules := []Rule{
+		{ID: 1, Country: "Sweden", City: WildcardString, Building: WildcardString, Role: "manager", DeviceType: WildcardString, Action: "allow"},
+		{ID: 2, Country: WildcardString, City: WildcardString, Building: WildcardString, Role: "manager", DeviceType: "Alarm", Action: "deny"},
+		{ID: 3, Country: "Norway", City: WildcardString, Building: WildcardString, Role: "guest", DeviceType: WildcardString, Action: "allow"},
+	}
+
+	res, err := access(rules, AccessQuery{Role: "manager"})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	expectedAllowed := []Combination{
+		{Country: "Sweden", City: WildcardString, Building: WildcardString, Role: "manager", DeviceType: WildcardString, AllowedBy: []ID{1}, DeniedBy: []ID{}},
+	}
+
+	if !reflect.DeepEqual(res.Allowed, expectedAllowed) {
+		t.Errorf("Expected allowed to be %v: %v", expectedAllowed, res.Allowed)
+	}
+
+	expectedDenied := []Combination{
+		{Country: "Sweden", City: WildcardString, Building: WildcardString, Role: "manager", DeviceType: "Alarm", AllowedBy: []ID{1}, DeniedBy: []ID{2}},
+		{Country: WildcardString, City: WildcardString, Building: WildcardString, Role: "manager", DeviceType: "Alarm", AllowedBy: []ID{}, DeniedBy: []ID{2}},
+	}
+
+	if len(res.Denied) != len(expectedDenied) {
+		t.Fatalf("Expected %d denied combinations: %v", len(expectedDenied), res.Denied)
+	}
+
+	for _, expected := range expectedDenied {
+		found := false
+		for _, combination := range res.Denied {
+			if reflect.DeepEqual(combination, expected) {
+				found = true
+			}
+		}
+
+		if !found {
+			t.Errorf("Expected denied to contain %v: %v", expected, res.Denied)
+		}
+	}
+
+	_, err = access(rules, AccessQuery{})
+	if err != nil {
+		t.Errorf("Expected err to be nil: %q", err)
+	}
+}
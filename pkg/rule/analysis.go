@@ -0,0 +1,190 @@
+package rule
+
+import "sort"
+
+const (
+	FindingDuplicate = "duplicate"
+	FindingRedundant = "redundant"
+	FindingShadowed  = "shadowed"
+)
+
+// Finding reports a rule that has no effect of its own because of the rules in By
+type Finding struct {
+	Type string `json:"type"`
+	ID   ID     `json:"id"`
+	By   []ID   `json:"by"`
+}
+
+// Analysis groups the rules that can be removed without changing any decision
+type Analysis struct {
+	// Duplicates have the same properties and action as an earlier rule
+	Duplicates []Finding `json:"duplicates"`
+	// Redundant rules are a strict subset of a broader rule with the same effect
+	Redundant []Finding `json:"redundant"`
+	// Shadowed allow rules only match inputs that are denied anyway
+	Shadowed []Finding `json:"shadowed"`
+}
+
+// Analyze finds duplicate, redundant and shadowed rules
+func (client *Client) Analyze() (Analysis, error) {
+	client.RLock()
+	defer client.RUnlock()
+
+	return analyze(sortedRules(client.rules))
+}
+
+// Findings returns the findings of the rule with the id
+func (analysis Analysis) Findings(id ID) []Finding {
+	var res []Finding
+	for _, findings := range [][]Finding{analysis.Duplicates, analysis.Redundant, analysis.Shadowed} {
+		for _, finding := range findings {
+			if finding.ID == id {
+				res = append(res, finding)
+			}
+		}
+	}
+
+	return res
+}
+
+func analyze(rules []Rule) (Analysis, error) {
+	res := Analysis{
+		Duplicates: []Finding{},
+		Redundant:  []Finding{},
+		Shadowed:   []Finding{},
+	}
+
+	for _, rule := range rules {
+		var duplicateOf, redundantTo []ID
+		for _, other := range rules {
+			if other.ID == rule.ID || isDeny(other) != isDeny(rule) {
+				continue
+			}
+
+			switch {
+			case attributes(other) == attributes(rule) && other.Action == rule.Action:
+				if other.ID < rule.ID {
+					duplicateOf = append(duplicateOf, other.ID)
+				}
+			case attributes(other) == attributes(rule):
+				// allow and undefined have the same effect
+				if other.ID < rule.ID {
+					redundantTo = append(redundantTo, other.ID)
+				}
+			case covers(other, rule):
+				redundantTo = append(redundantTo, other.ID)
+			}
+		}
+
+		if len(duplicateOf) > 0 {
+			res.Duplicates = append(res.Duplicates, Finding{Type: FindingDuplicate, ID: rule.ID, By: duplicateOf})
+		}
+
+		if len(redundantTo) > 0 {
+			res.Redundant = append(res.Redundant, Finding{Type: FindingRedundant, ID: rule.ID, By: redundantTo})
+		}
+
+		if isDeny(rule) {
+			continue
+		}
+
+		shadowedBy, err := shadowedBy(rules, rule)
+		if err != nil {
+			return Analysis{}, err
+		}
+
+		if len(shadowedBy) > 0 {
+			res.Shadowed = append(res.Shadowed, Finding{Type: FindingShadowed, ID: rule.ID, By: shadowedBy})
+		}
+	}
+
+	return res, nil
+}
+
+// shadowedBy returns the deny rules overriding the allow rule, or nothing if the rule allows at least one input
+func shadowedBy(rules []Rule, rule Rule) ([]ID, error) {
+	var query [attributeCount]string
+	for i, value := range attributes(rule) {
+		if value != WildcardString {
+			query[i] = value
+		}
+	}
+
+	access, err := access(rules, AccessQuery{
+		Country:    query[0],
+		City:       query[1],
+		Building:   query[2],
+		Role:       query[3],
+		DeviceType: query[4],
+	})
+
+	// too many combinations to check, fall back to only looking for a single broader deny
+	if err == ErrorAccessQueryTooWide {
+		var res []ID
+		for _, other := range rules {
+			if isDeny(other) && covers(other, rule) {
+				res = append(res, other.ID)
+			}
+		}
+
+		return res, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, combination := range access.Allowed {
+		for _, id := range combination.AllowedBy {
+			if id == rule.ID {
+				return nil, nil
+			}
+		}
+	}
+
+	denies := make(map[ID]bool)
+	for _, combination := range access.Denied {
+		if !containsID(combination.AllowedBy, rule.ID) {
+			continue
+		}
+
+		for _, id := range combination.DeniedBy {
+			denies[id] = true
+		}
+	}
+
+	var res []ID
+	for id := range denies {
+		res = append(res, id)
+	}
+
+	sort.Ints(res)
+
+	return res, nil
+}
+
+// covers returns true if every input matched by rule is also matched by other
+func covers(other Rule, rule Rule) bool {
+	ruleAttributes := attributes(rule)
+	for i, value := range attributes(other) {
+		if value != WildcardString && value != ruleAttributes[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isDeny(rule Rule) bool {
+	return ToAction(rule.Action) == ActionDeny
+}
+
+func containsID(ids []ID, id ID) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,42 @@
+package rule
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	rules := []Rule{
+		{ID: 1, Country: "Sweden", City: WildcardString, Building: WildcardString, Role: "manager", DeviceType: WildcardString, Action: "allow"},
+		{ID: 2, Country: "Sweden", City: "Gothenburg", Building: WildcardString, Role: "manager", DeviceType: "Printer", Action: "allow"},
+		{ID: 3, Country: "Sweden", City: WildcardString, Building: WildcardString, Role: "manager", DeviceType: WildcardString, Action: "allow"},
+		{ID: 4, Country: WildcardString, City: WildcardString, Building: "HQ", Role: "guest", DeviceType: WildcardString, Action: "deny"},
+		{ID: 5, Country: WildcardString, City: WildcardString, Building: "Branch", Role: "guest", DeviceType: WildcardString, Action: "deny"},
+		{ID: 6, Country: "Norway", City: "Oslo", Building: "HQ", Role: "guest", DeviceType: "Alarm", Action: "allow"},
+	}
+
+	analysis, err := analyze(rules)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	expected := Analysis{
+		Duplicates: []Finding{
+			{Type: FindingDuplicate, ID: 3, By: []ID{1}},
+		},
+		Redundant: []Finding{
+			{Type: FindingRedundant, ID: 2, By: []ID{1, 3}},
+		},
+		Shadowed: []Finding{
+			{Type: FindingShadowed, ID: 6, By: []ID{4}},
+		},
+	}
+
+	if !reflect.DeepEqual(analysis, expected) {
+		t.Errorf("Expected analysis to be %v: %v", expected, analysis)
+	}
+
+	if len(analysis.Findings(2)) != 1 {
+		t.Errorf("Expected one finding for rule 2: %v", analysis.Findings(2))
+	}
+}
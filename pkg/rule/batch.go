@@ -0,0 +1,223 @@
+package rule
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrorBatchEmpty       = errors.New("Batch has no operations")
+	ErrorBatchFailed      = errors.New("Batch failed, no operation was applied")
+	ErrorOperationUnknown = errors.New("Unknown operation, use create, update or delete")
+)
+
+const (
+	OperationCreate = "create"
+	OperationUpdate = "update"
+	OperationDelete = "delete"
+)
+
+// Operation is a single change of a batch, update replaces every property of the rule like PUT
+type Operation struct {
+	Op string `json:"op"`
+	// ID of the rule to update or delete, created rules get a new ID
+	ID   ID   `json:"id,omitempty"`
+	Rule Rule `json:"rule"`
+	// IfMatch is the ETag the rule has to match before the update or delete, empty skips the check
+	IfMatch string `json:"if_match,omitempty"`
+}
+
+// OperationResult is the rule after an operation, deleted rules only have the ID
+type OperationResult struct {
+	Op   string `json:"op"`
+	ID   ID     `json:"id"`
+	Rule *Rule  `json:"rule,omitempty"`
+}
+
+// BatchResult is the revision after all operations were applied
+type BatchResult struct {
+	Revision   string            `json:"revision"`
+	Operations []OperationResult `json:"operations"`
+}
+
+// OperationError is the reason an operation of a batch failed
+type OperationError struct {
+	Index      int         `json:"index"`
+	Op         string      `json:"op"`
+	ID         ID          `json:"id,omitempty"`
+	Message    string      `json:"message"`
+	Violations []Violation `json:"violations,omitempty"`
+	err        error
+}
+
+// BatchError is returned when at least one operation failed, with the error of every failed operation
+type BatchError struct {
+	Message string           `json:"message"`
+	Errors  []OperationError `json:"errors"`
+}
+
+func (e *BatchError) Error() string {
+	var errs []string
+	for _, operationErr := range e.Errors {
+		errs = append(errs, fmt.Sprintf("%d (%s): %s", operationErr.Index, operationErr.Op, operationErr.Message))
+	}
+
+	return fmt.Sprintf("%s: %s", e.Message, strings.Join(errs, ", "))
+}
+
+// Unwrap makes errors.Is(err, ErrorBatchFailed) work for batch errors
+func (e *BatchError) Unwrap() error {
+	return ErrorBatchFailed
+}
+
+// Is returns true if any operation failed with the target, like ErrorPreconditionFailed
+func (e *BatchError) Is(target error) bool {
+	for _, operationErr := range e.Errors {
+		if errors.Is(operationErr.err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Batch applies all operations as a single change with one revision, or none of them if any operation fails
+func (client *Client) Batch(operations []Operation, author string, ifMatch string) (BatchResult, error) {
+	client.Lock()
+	defer client.Unlock()
+
+	if len(operations) == 0 {
+		return BatchResult{}, ErrorBatchEmpty
+	}
+
+	err := client.checkRevisionWithoutLock(ifMatch)
+	if err != nil {
+		return BatchResult{}, err
+	}
+
+	index := client.Index
+	rules := client.copyRules()
+	results := make([]OperationResult, len(operations))
+	// producer is the index of the last operation that created or updated the rule
+	producer := make(map[ID]int)
+	var errs []OperationError
+
+	for i, operation := range operations {
+		id, err := applyOperation(rules, &index, operation)
+		if err != nil {
+			errs = append(errs, OperationError{Index: i, Op: operation.Op, ID: operation.ID, Message: err.Error(), err: err})
+			continue
+		}
+
+		results[i] = OperationResult{Op: operation.Op, ID: id}
+
+		if operation.Op == OperationDelete {
+			delete(producer, id)
+			continue
+		}
+
+		producer[id] = i
+	}
+
+	// the rules are validated in their final state, against the unchanged rules and the rules of earlier operations
+	for i := range operations {
+		id := results[i].ID
+		last, found := producer[id]
+		if results[i].Op == OperationDelete || !found || last != i {
+			continue
+		}
+
+		var others []Rule
+		for _, other := range sortedRules(rules) {
+			otherProducer, changed := producer[other.ID]
+			if other.ID != id && (!changed || otherProducer < i) {
+				others = append(others, other)
+			}
+		}
+
+		violations := client.validation.validate(rules[id], others)
+		if len(violations) > 0 {
+			errs = append(errs, OperationError{Index: i, Op: operations[i].Op, ID: id, Message: ErrorRuleNotValid.Error(), Violations: violations, err: ErrorRuleNotValid})
+		}
+	}
+
+	if len(errs) > 0 {
+		sortOperationErrors(errs)
+
+		return BatchResult{}, &BatchError{
+			Message: ErrorBatchFailed.Error(),
+			Errors:  errs,
+		}
+	}
+
+	err = client.apply(index, rules, author)
+	if err != nil {
+		return BatchResult{}, err
+	}
+
+	for i := range results {
+		rule, found := client.rules[results[i].ID]
+		if results[i].Op != OperationDelete && found {
+			results[i].Rule = &rule
+		}
+	}
+
+	revision, err := hashRules(sortedRules(client.rules))
+	if err != nil {
+		return BatchResult{}, err
+	}
+
+	return BatchResult{
+		Revision:   revision,
+		Operations: results,
+	}, nil
+}
+
+// applyOperation changes the rules and returns the ID of the rule that was changed
+func applyOperation(rules map[ID]Rule, index *int, operation Operation) (ID, error) {
+	rule := operation.Rule
+	rule.Action = FromAction(ToAction(rule.Action))
+
+	switch operation.Op {
+	case OperationCreate:
+		*index++
+		rule.ID = *index
+		rules[rule.ID] = rule
+
+		return rule.ID, nil
+	case OperationUpdate, OperationDelete:
+		if operation.Op == OperationUpdate && rule.ID != NullID && rule.ID != operation.ID {
+			return NullID, ErrorIdNotChangeable
+		}
+
+		current, found := rules[operation.ID]
+		if !found {
+			return NullID, ErrorIdNotFound
+		}
+
+		err := checkETag(current, operation.IfMatch)
+		if err != nil {
+			return NullID, err
+		}
+
+		if operation.Op == OperationDelete {
+			delete(rules, operation.ID)
+			return operation.ID, nil
+		}
+
+		rule.ID = operation.ID
+		rules[rule.ID] = rule
+
+		return rule.ID, nil
+	default:
+		return NullID, ErrorOperationUnknown
+	}
+}
+
+func sortOperationErrors(errs []OperationError) {
+	sort.SliceStable(errs, func(i, j int) bool {
+		return errs[i].Index < errs[j].Index
+	})
+}
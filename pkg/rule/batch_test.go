@@ -0,0 +1,91 @@
+package rule
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBatch(t *testing.T) {
+	client := NewClient()
+
+	sweden := Rule{Country: "Sweden", City: WildcardString, Building: WildcardString, Role: "sweden_admin", DeviceType: WildcardString, Action: "allow"}
+	norway := Rule{Country: "Norway", City: WildcardString, Building: WildcardString, Role: "norway_admin", DeviceType: WildcardString, Action: "allow"}
+	denmark := Rule{Country: "Denmark", City: WildcardString, Building: WildcardString, Role: "denmark_admin", DeviceType: WildcardString, Action: "allow"}
+
+	_, err := client.Batch([]Operation{
+		{Op: OperationCreate, Rule: sweden},
+		{Op: OperationCreate, Rule: norway},
+	}, NullAuthor, "")
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	revisions := len(client.GetRevisions())
+	before, _ := client.Revision()
+
+	_, err = client.Batch([]Operation{
+		{Op: OperationDelete, ID: 1},
+		{Op: OperationUpdate, ID: 5, Rule: denmark},
+		{Op: OperationCreate, Rule: norway},
+		{Op: "move"},
+	}, NullAuthor, "")
+
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("Expected a batch error: %q", err)
+	}
+
+	expectedIndexes := []int{1, 2, 3}
+	if len(batchErr.Errors) != len(expectedIndexes) {
+		t.Fatalf("Expected errors for the operations %v: %v", expectedIndexes, batchErr.Errors)
+	}
+
+	for i, index := range expectedIndexes {
+		if batchErr.Errors[i].Index != index {
+			t.Errorf("Expected error %d to be for operation %d: %v", i, index, batchErr.Errors[i])
+		}
+	}
+
+	after, _ := client.Revision()
+	if after != before {
+		t.Errorf("Expected a failed batch to not change the rules")
+	}
+
+	res, err := client.Batch([]Operation{
+		{Op: OperationDelete, ID: 1},
+		{Op: OperationUpdate, ID: 2, Rule: denmark},
+		{Op: OperationCreate, Rule: norway},
+	}, NullAuthor, before)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	if len(client.GetRevisions()) != revisions+1 {
+		t.Errorf("Expected exactly one new revision: %v", client.GetRevisions())
+	}
+
+	if res.Operations[2].ID != 3 || res.Operations[2].Rule == nil || res.Operations[2].Rule.Role != "norway_admin" {
+		t.Errorf("Expected rule 3 to be created: %v", res.Operations[2])
+	}
+
+	_, err = client.Batch([]Operation{{Op: OperationDelete, ID: 3}}, NullAuthor, before)
+	if !errors.Is(err, ErrorPreconditionFailed) {
+		t.Errorf("Expected batch with an old revision to fail: %q", err)
+	}
+
+	_, err = client.Batch([]Operation{{Op: OperationDelete, ID: 3, IfMatch: `"stale"`}}, NullAuthor, "")
+	if !errors.As(err, &batchErr) || !errors.Is(err, ErrorPreconditionFailed) {
+		t.Errorf("Expected operation with an old ETag to fail like DELETE: %q", err)
+	}
+
+	changedID := denmark
+	changedID.ID = 3
+	_, err = client.Batch([]Operation{{Op: OperationUpdate, ID: 2, Rule: changedID}}, NullAuthor, "")
+	if !errors.As(err, &batchErr) || !errors.Is(err, ErrorIdNotChangeable) {
+		t.Errorf("Expected update of the ID to fail like PUT: %q", err)
+	}
+
+	if errors.Is(err, ErrorPreconditionFailed) {
+		t.Errorf("Expected the batch error to only match the errors of the operations: %q", err)
+	}
+}
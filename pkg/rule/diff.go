@@ -0,0 +1,141 @@
+package rule
+
+// FieldChange is a single field that differs between two versions of a rule
+type FieldChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// ModifiedRule is a rule that exists in both revisions but with different fields
+type ModifiedRule struct {
+	ID      ID            `json:"id"`
+	From    Rule          `json:"from"`
+	To      Rule          `json:"to"`
+	Changes []FieldChange `json:"changes"`
+}
+
+// Diff is the difference between two revisions, rules are matched by ID
+type Diff struct {
+	From     string         `json:"from"`
+	To       string         `json:"to"`
+	Added    []Rule         `json:"added"`
+	Removed  []Rule         `json:"removed"`
+	Modified []ModifiedRule `json:"modified"`
+}
+
+// DiffRevisions returns the difference between two revisions, the current rules can be used with their revision even if the history has been trimmed
+func (client *Client) DiffRevisions(from string, to string) (Diff, error) {
+	client.RLock()
+	defer client.RUnlock()
+
+	fromRules, err := client.getRevisionRulesWithoutLock(from)
+	if err != nil {
+		return Diff{}, err
+	}
+
+	toRules, err := client.getRevisionRulesWithoutLock(to)
+	if err != nil {
+		return Diff{}, err
+	}
+
+	diff := DiffRules(fromRules, toRules)
+	diff.From = from
+	diff.To = to
+
+	return diff, nil
+}
+
+func (client *Client) getRevisionRulesWithoutLock(revision string) ([]Rule, error) {
+	current := sortedRules(client.rules)
+
+	hash, err := hashRules(current)
+	if err != nil {
+		return nil, err
+	}
+
+	if hash == revision {
+		return current, nil
+	}
+
+	snapshot, err := client.getRevisionWithoutLock(revision)
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshot.Rules, nil
+}
+
+// DiffRules returns the added, removed and modified rules between two rule sets
+func DiffRules(from []Rule, to []Rule) Diff {
+	diff := Diff{
+		Added:    []Rule{},
+		Removed:  []Rule{},
+		Modified: []ModifiedRule{},
+	}
+
+	fromRules := make(map[ID]Rule)
+	for _, rule := range from {
+		fromRules[rule.ID] = rule
+	}
+
+	toRules := make(map[ID]Rule)
+	for _, rule := range to {
+		toRules[rule.ID] = rule
+	}
+
+	for _, rule := range from {
+		_, found := toRules[rule.ID]
+		if !found {
+			diff.Removed = append(diff.Removed, rule)
+		}
+	}
+
+	for _, rule := range to {
+		oldRule, found := fromRules[rule.ID]
+		if !found {
+			diff.Added = append(diff.Added, rule)
+			continue
+		}
+
+		changes := diffFields(oldRule, rule)
+		if len(changes) > 0 {
+			diff.Modified = append(diff.Modified, ModifiedRule{
+				ID:      rule.ID,
+				From:    oldRule,
+				To:      rule,
+				Changes: changes,
+			})
+		}
+	}
+
+	return diff
+}
+
+func diffFields(from Rule, to Rule) []FieldChange {
+	fields := []struct {
+		name string
+		from string
+		to   string
+	}{
+		{"country", from.Country, to.Country},
+		{"city", from.City, to.City},
+		{"building", from.Building, to.Building},
+		{"role", from.Role, to.Role},
+		{"device_type", from.DeviceType, to.DeviceType},
+		{"action", from.Action, to.Action},
+	}
+
+	var changes []FieldChange
+	for _, field := range fields {
+		if field.from != field.to {
+			changes = append(changes, FieldChange{
+				Field: field.name,
+				From:  field.from,
+				To:    field.to,
+			})
+		}
+	}
+
+	return changes
+}
@@ -0,0 +1,72 @@
+package rule
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/xenitab/opa-bundle-api/pkg/util"
+)
+
+var (
+	ErrorPreconditionFailed = errors.New("Precondition failed, the rules have changed")
+)
+
+// ETag returns the entity tag of the rule, it changes with every change of the properties
+func ETag(rule Rule) (string, error) {
+	data, err := json.Marshal(&rule)
+	if err != nil {
+		return NullRuleString, ErrorUnableToMarshalJSON
+	}
+
+	hash, err := util.BytesToHash(data)
+	if err != nil {
+		return NullRuleString, ErrorUnableToHashRules
+	}
+
+	return hash, nil
+}
+
+// matchIfMatch returns true if the value of an If-Match header matches the entity tag, an empty value always matches
+func matchIfMatch(ifMatch string, etag string) bool {
+	if ifMatch == "" {
+		return true
+	}
+
+	for _, value := range strings.Split(ifMatch, ",") {
+		value = strings.TrimSpace(value)
+		if value == "*" || strings.Trim(value, `"`) == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkETag returns ErrorPreconditionFailed if the rule doesn't match the If-Match value
+func checkETag(rule Rule, ifMatch string) error {
+	etag, err := ETag(rule)
+	if err != nil {
+		return err
+	}
+
+	if !matchIfMatch(ifMatch, etag) {
+		return ErrorPreconditionFailed
+	}
+
+	return nil
+}
+
+// checkRevisionWithoutLock returns ErrorPreconditionFailed if the revision of the rules doesn't match the If-Match value
+func (client *Client) checkRevisionWithoutLock(ifMatch string) error {
+	revision, err := hashRules(sortedRules(client.rules))
+	if err != nil {
+		return err
+	}
+
+	if !matchIfMatch(ifMatch, revision) {
+		return ErrorPreconditionFailed
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package rule
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestIfMatch(t *testing.T) {
+	client := NewClient()
+
+	opts := Options{
+		Country:    "Sweden",
+		City:       WildcardString,
+		Building:   WildcardString,
+		Role:       "sweden_admin",
+		DeviceType: WildcardString,
+		Action:     ActionAllow,
+	}
+
+	id, _ := client.Add(opts)
+	initial, _ := client.Revision()
+
+	rule, _ := client.Get(id)
+	etag, err := ETag(rule)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	opts.City = "Alingsås"
+	opts.IfMatch = fmt.Sprintf("%q", etag)
+	err = client.Update(id, opts)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	// the first update changed the ETag
+	opts.City = "Gothenburg"
+	err = client.Update(id, opts)
+	if !errors.Is(err, ErrorPreconditionFailed) {
+		t.Errorf("Expected update with an old ETag to fail: %q", err)
+	}
+
+	err = client.Delete(id, NullAuthor, etag)
+	if !errors.Is(err, ErrorPreconditionFailed) {
+		t.Errorf("Expected delete with an old ETag to fail: %q", err)
+	}
+
+	err = client.Patch(id, []byte(`{"city": "Gothenburg"}`), NullAuthor, "*")
+	if err != nil {
+		t.Errorf("Expected err to be nil: %q", err)
+	}
+
+	_, err = client.Rollback(initial, NullAuthor, initial)
+	if !errors.Is(err, ErrorPreconditionFailed) {
+		t.Errorf("Expected rollback with an old revision to fail: %q", err)
+	}
+
+	current, _ := client.Revision()
+	_, err = client.Rollback(initial, NullAuthor, current)
+	if err != nil {
+		t.Errorf("Expected err to be nil: %q", err)
+	}
+}
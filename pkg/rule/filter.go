@@ -0,0 +1,70 @@
+package rule
+
+// Filter selects the rules for a subset of the agents, an empty field matches every rule
+type Filter struct {
+	Country    []string `json:"country,omitempty"`
+	City       []string `json:"city,omitempty"`
+	Building   []string `json:"building,omitempty"`
+	Role       []string `json:"role,omitempty"`
+	DeviceType []string `json:"device_type,omitempty"`
+}
+
+// Match returns true if the rule can apply to an agent selected by the filter, rules with a wildcard always match
+func (filter Filter) Match(rule Rule) bool {
+	return matchValues(filter.Country, rule.Country) &&
+		matchValues(filter.City, rule.City) &&
+		matchValues(filter.Building, rule.Building) &&
+		matchValues(filter.Role, rule.Role) &&
+		matchValues(filter.DeviceType, rule.DeviceType)
+}
+
+// GetFilteredJSON returns the rules matching the filter, in the same format as GetAllJSON
+func (client *Client) GetFilteredJSON(filter Filter) (string, error) {
+	client.RLock()
+	defer client.RUnlock()
+
+	return filteredJSON(sortedRules(client.rules), filter)
+}
+
+// GetRevisionFilteredJSON returns the rules of the revision matching the filter, in the same format as GetFilteredJSON
+func (client *Client) GetRevisionFilteredJSON(revision string, filter Filter) (string, error) {
+	client.RLock()
+	defer client.RUnlock()
+
+	rules, err := client.getRevisionRulesWithoutLock(revision)
+	if err != nil {
+		return NullRuleString, err
+	}
+
+	return filteredJSON(rules, filter)
+}
+
+func filteredJSON(rules []Rule, filter Filter) (string, error) {
+	filtered := []Rule{}
+	for _, rule := range rules {
+		if filter.Match(rule) {
+			filtered = append(filtered, rule)
+		}
+	}
+
+	res, err := marshalRules(filtered)
+	if err != nil {
+		return NullRuleString, err
+	}
+
+	return string(res), nil
+}
+
+func matchValues(values []string, value string) bool {
+	if len(values) == 0 || value == WildcardString {
+		return true
+	}
+
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,38 @@
+package rule
+
+import "testing"
+
+func TestFilter(t *testing.T) {
+	filter := Filter{
+		Country: []string{"Sweden", "Norway"},
+		Role:    []string{"user"},
+	}
+
+	cases := []struct {
+		rule     Rule
+		expected bool
+	}{
+		{
+			rule:     Rule{Country: "Sweden", City: "Gothenburg", Role: "user"},
+			expected: true,
+		},
+		{
+			rule:     Rule{Country: WildcardString, City: "Gothenburg", Role: "user"},
+			expected: true,
+		},
+		{
+			rule:     Rule{Country: "Denmark", City: "Copenhagen", Role: "user"},
+			expected: false,
+		},
+		{
+			rule:     Rule{Country: "Norway", City: "Oslo", Role: "guest"},
+			expected: false,
+		},
+	}
+
+	for _, c := range cases {
+		if filter.Match(c.rule) != c.expected {
+			t.Errorf("Expected match of %v to be %t", c.rule, c.expected)
+		}
+	}
+}
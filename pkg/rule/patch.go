@@ -0,0 +1,86 @@
+package rule
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrorPatchNotValid   = errors.New("Patch not valid")
+	ErrorIdNotChangeable = errors.New("ID can't be changed")
+)
+
+// mergePatch returns the rule with the JSON Merge Patch applied
+func mergePatch(rule Rule, patch []byte) (Rule, error) {
+	var patchValue interface{}
+	err := json.Unmarshal(patch, &patchValue)
+	if err != nil {
+		return NullRule, fmt.Errorf("%w: %s", ErrorPatchNotValid, err)
+	}
+
+	// a patch that isn't an object would replace the whole rule with something that isn't a rule
+	patchObject, ok := patchValue.(map[string]interface{})
+	if !ok {
+		return NullRule, fmt.Errorf("%w: must be an object", ErrorPatchNotValid)
+	}
+
+	current, err := json.Marshal(&rule)
+	if err != nil {
+		return NullRule, ErrorUnableToMarshalJSON
+	}
+
+	var target map[string]interface{}
+	err = json.Unmarshal(current, &target)
+	if err != nil {
+		return NullRule, err
+	}
+
+	merged, err := json.Marshal(mergeObjects(target, patchObject))
+	if err != nil {
+		return NullRule, ErrorUnableToMarshalJSON
+	}
+
+	var res Rule
+	decoder := json.NewDecoder(bytes.NewReader(merged))
+	decoder.DisallowUnknownFields()
+
+	err = decoder.Decode(&res)
+	if err != nil {
+		return NullRule, fmt.Errorf("%w: %s", ErrorPatchNotValid, err)
+	}
+
+	if res.ID != rule.ID {
+		return NullRule, ErrorIdNotChangeable
+	}
+
+	res.Action = FromAction(ToAction(res.Action))
+
+	return res, nil
+}
+
+// mergeObjects merges the patch into the target, null removes the member
+func mergeObjects(target map[string]interface{}, patch map[string]interface{}) map[string]interface{} {
+	for key, value := range patch {
+		if value == nil {
+			delete(target, key)
+			continue
+		}
+
+		patchObject, ok := value.(map[string]interface{})
+		if !ok {
+			target[key] = value
+			continue
+		}
+
+		targetObject, ok := target[key].(map[string]interface{})
+		if !ok {
+			targetObject = make(map[string]interface{})
+		}
+
+		target[key] = mergeObjects(targetObject, patchObject)
+	}
+
+	return target
+}
@@ -0,0 +1,87 @@
+package rule
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUpdateAndPatch(t *testing.T) {
+	client := NewClient()
+
+	opts := Options{
+		Country:    "Sweden",
+		City:       WildcardString,
+		Building:   WildcardString,
+		Role:       "sweden_admin",
+		DeviceType: WildcardString,
+		Action:     ActionAllow,
+	}
+
+	id, err := client.Add(opts)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	err = client.Update(2, opts)
+	if !errors.Is(err, ErrorIdNotFound) {
+		t.Errorf("Expected update of a missing rule to return not found: %q", err)
+	}
+
+	err = client.Update(id, Options{Country: "Norway", Role: "norway_admin", Action: ActionAllow})
+	if !errors.Is(err, ErrorRuleNotValid) {
+		t.Errorf("Expected update without every property to not be valid: %q", err)
+	}
+
+	err = client.Patch(id, []byte(`{"country": "Norway", "role": "norway_admin"}`), NullAuthor, "")
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	rule, _ := client.Get(id)
+	expected := Rule{ID: id, Country: "Norway", City: WildcardString, Building: WildcardString, Role: "norway_admin", DeviceType: WildcardString, Action: "allow"}
+	if rule != expected {
+		t.Errorf("Expected rule to be %v: %v", expected, rule)
+	}
+
+	cases := []struct {
+		patch       string
+		expectedErr error
+	}{
+		{patch: `{"city": null}`, expectedErr: ErrorRuleNotValid},
+		{patch: `{"id": 5}`, expectedErr: ErrorIdNotChangeable},
+		{patch: `{"floor": "1"}`, expectedErr: ErrorPatchNotValid},
+		{patch: `["country"]`, expectedErr: ErrorPatchNotValid},
+	}
+
+	for _, c := range cases {
+		err := client.Patch(id, []byte(c.patch), NullAuthor, "")
+		if !errors.Is(err, c.expectedErr) {
+			t.Errorf("Expected patch %s to return %q: %q", c.patch, c.expectedErr, err)
+		}
+	}
+
+	deny := opts
+	deny.Role = "guest"
+	deny.Action = ActionDeny
+
+	denyID, err := client.Add(deny)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	err = client.Patch(denyID, []byte(`{"action": null}`), NullAuthor, "")
+	if !errors.Is(err, ErrorRuleNotValid) {
+		t.Errorf("Expected patch removing the action to not be valid: %q", err)
+	}
+
+	deny.Action = ActionUndefined
+	err = client.Update(denyID, deny)
+	if !errors.Is(err, ErrorRuleNotValid) {
+		t.Errorf("Expected update without an action to not be valid: %q", err)
+	}
+
+	rule, _ = client.Get(denyID)
+	if rule.Action != "deny" {
+		t.Errorf("Expected the rule to still deny: %v", rule)
+	}
+}
@@ -0,0 +1,251 @@
+package rule
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/xenitab/opa-bundle-api/pkg/util"
+)
+
+var (
+	NullRevision           = Revision{}
+	NullAuthor             = ""
+	DefaultRevisionLimit   = 100
+	ErrorRevisionNotFound  = errors.New("Revision not found")
+	ErrorUnableToHashRules = errors.New("Unable to hash rules")
+)
+
+// Revision is a snapshot of all rules, the hash is the same as the revision of the bundle
+type Revision struct {
+	Revision  string    `json:"revision"`
+	Timestamp time.Time `json:"timestamp"`
+	Author    string    `json:"author"`
+	Rules     []Rule    `json:"rules"`
+}
+
+// RevisionSummary is a Revision without the rule snapshot
+type RevisionSummary struct {
+	Revision  string    `json:"revision"`
+	Timestamp time.Time `json:"timestamp"`
+	Author    string    `json:"author"`
+	RuleCount int       `json:"rule_count"`
+}
+
+// Revision returns the hash of the current rules
+func (client *Client) Revision() (string, error) {
+	client.RLock()
+	defer client.RUnlock()
+
+	return hashRules(sortedRules(client.rules))
+}
+
+// GetAllWithRevision returns the rules together with their revision, the revision can be used as a precondition of bulk operations
+func (client *Client) GetAllWithRevision() ([]Rule, string, error) {
+	client.RLock()
+	defer client.RUnlock()
+
+	rules := sortedRules(client.rules)
+
+	revision, err := hashRules(rules)
+	if err != nil {
+		return nil, NullRuleString, err
+	}
+
+	return rules, revision, nil
+}
+
+// GetRevisions returns a summary of the revision history, newest first
+func (client *Client) GetRevisions() []RevisionSummary {
+	client.RLock()
+	defer client.RUnlock()
+
+	summaries := []RevisionSummary{}
+	for i := len(client.revisions) - 1; i >= 0; i-- {
+		revision := client.revisions[i]
+		summaries = append(summaries, RevisionSummary{
+			Revision:  revision.Revision,
+			Timestamp: revision.Timestamp,
+			Author:    revision.Author,
+			RuleCount: len(revision.Rules),
+		})
+	}
+
+	return summaries
+}
+
+// GetRevision returns the latest recorded snapshot with the revision hash
+func (client *Client) GetRevision(revision string) (Revision, error) {
+	client.RLock()
+	defer client.RUnlock()
+
+	return client.getRevisionWithoutLock(revision)
+}
+
+// GetRevisionJSON returns the rules of the revision in the same format as GetAllJSON
+func (client *Client) GetRevisionJSON(revision string) (string, error) {
+	client.RLock()
+	defer client.RUnlock()
+
+	rules, err := client.getRevisionRulesWithoutLock(revision)
+	if err != nil {
+		return NullRuleString, err
+	}
+
+	res, err := marshalRules(rules)
+	if err != nil {
+		return NullRuleString, err
+	}
+
+	return string(res), nil
+}
+
+// Rollback replaces the current rules with the snapshot from the revision, ifMatch is the value of an If-Match header the current revision has to match
+func (client *Client) Rollback(revision string, author string, ifMatch string) (Revision, error) {
+	client.Lock()
+	defer client.Unlock()
+
+	err := client.checkRevisionWithoutLock(ifMatch)
+	if err != nil {
+		return NullRevision, err
+	}
+
+	target, err := client.getRevisionWithoutLock(revision)
+	if err != nil {
+		return NullRevision, err
+	}
+
+	err = client.replaceWithoutLock(target.Rules, author)
+	if err != nil {
+		return NullRevision, err
+	}
+
+	return client.revisions[len(client.revisions)-1], nil
+}
+
+func (client *Client) getRevisionWithoutLock(revision string) (Revision, error) {
+	for i := len(client.revisions) - 1; i >= 0; i-- {
+		if client.revisions[i].Revision == revision {
+			return client.revisions[i], nil
+		}
+	}
+
+	return NullRevision, ErrorRevisionNotFound
+}
+
+// appendRevision returns the history with the rules appended, unless they didn't change since the latest revision
+func (client *Client) appendRevision(rules []Rule, author string) ([]Revision, error) {
+	hash, err := hashRules(rules)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(client.revisions) > 0 && client.revisions[len(client.revisions)-1].Revision == hash {
+		return client.revisions, nil
+	}
+
+	revision := Revision{
+		Revision:  hash,
+		Timestamp: time.Now().UTC(),
+		Author:    author,
+		Rules:     rules,
+	}
+
+	revisions := append(append([]Revision{}, client.revisions...), revision)
+	if client.revisionLimit > 0 && len(revisions) > client.revisionLimit {
+		revisions = revisions[len(revisions)-client.revisionLimit:]
+	}
+
+	return revisions, nil
+}
+
+// logRevisions appends the newest revision to the revision log and returns the amount of revisions in the log,
+// once the log has twice the limit it is replaced with the revisions within the limit
+func (client *Client) logRevisions(revisions []Revision) (int, error) {
+	// appendRevision doesn't add a revision if the rules didn't change
+	unchanged := len(client.revisions) > 0 && revisions[len(revisions)-1].Revision == client.revisions[len(client.revisions)-1].Revision
+	if len(revisions) == 0 || unchanged {
+		return client.loggedRevisions, nil
+	}
+
+	if client.revisionLimit > 0 && client.loggedRevisions+1 >= 2*client.revisionLimit {
+		values := make([]interface{}, len(revisions))
+		for i := range revisions {
+			values[i] = &revisions[i]
+		}
+
+		err := client.revisionLog.Replace(values)
+		if err != nil {
+			return 0, err
+		}
+
+		return len(revisions), nil
+	}
+
+	err := client.revisionLog.Append(&revisions[len(revisions)-1])
+	if err != nil {
+		return 0, err
+	}
+
+	return client.loggedRevisions + 1, nil
+}
+
+// migrateRevisions moves the revisions from the state of the store to the revision log
+func (client *Client) migrateRevisions(index int, revisions []Revision) error {
+	values := make([]interface{}, len(revisions))
+	for i := range revisions {
+		values[i] = &revisions[i]
+	}
+
+	err := client.revisionLog.Replace(values)
+	if err != nil {
+		return err
+	}
+
+	err = client.store.Save(&State{
+		Index: index,
+		Rules: sortedRules(client.rules),
+	})
+	if err != nil {
+		return err
+	}
+
+	client.revisions = revisions
+	client.loggedRevisions = len(revisions)
+
+	return nil
+}
+
+func loadRevisions(revisionLog util.Log) ([]Revision, error) {
+	values, err := revisionLog.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := []Revision{}
+	for _, value := range values {
+		var revision Revision
+		err := json.Unmarshal(value, &revision)
+		if err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, nil
+}
+
+func hashRules(rules []Rule) (string, error) {
+	data, err := marshalRules(rules)
+	if err != nil {
+		return NullRuleString, err
+	}
+
+	hash, err := util.BytesToHash(data)
+	if err != nil {
+		return NullRuleString, ErrorUnableToHashRules
+	}
+
+	return hash, nil
+}
@@ -0,0 +1,119 @@
+package rule
+
+import (
+	"testing"
+)
+
+func TestRollback(t *testing.T) {
+	client := NewClient()
+
+	opts := Options{
+		Country:    "Sweden",
+		City:       WildcardString,
+		Building:   WildcardString,
+		Role:       "sweden_admin",
+		DeviceType: WildcardString,
+		Action:     ActionAllow,
+		Author:     "alice",
+	}
+
+	_, err := client.Add(opts)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	knownGood, err := client.Revision()
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	opts.Role = "guest"
+	opts.Action = ActionDeny
+	opts.Author = "bob"
+
+	_, err = client.Add(opts)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	revisions := client.GetRevisions()
+	if len(revisions) != 2 {
+		t.Fatalf("Expected 2 revisions but was: %d", len(revisions))
+	}
+
+	if revisions[0].Author != "bob" || revisions[1].Revision != knownGood {
+		t.Errorf("Expected newest revision first but was: %v", revisions)
+	}
+
+	revision, err := client.Rollback(knownGood, "carol", "")
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	if revision.Revision != knownGood || revision.Author != "carol" {
+		t.Errorf("Expected rollback to record revision %s by carol but was: %s by %s", knownGood, revision.Revision, revision.Author)
+	}
+
+	current, _ := client.Revision()
+	if current != knownGood {
+		t.Errorf("Expected current revision to be %s but was: %s", knownGood, current)
+	}
+
+	id, _ := client.Add(opts)
+	if id != 3 {
+		t.Errorf("Expected ID to stay monotonic after rollback but was: %d", id)
+	}
+
+	_, err = client.Rollback("unknown", "carol", "")
+	if err != ErrorRevisionNotFound {
+		t.Errorf("Expected err to be ErrorRevisionNotFound but was: %q", err)
+	}
+}
+
+func TestDiffRevisions(t *testing.T) {
+	client := NewClient()
+
+	opts := Options{
+		Country:    "Sweden",
+		City:       WildcardString,
+		Building:   WildcardString,
+		Role:       "sweden_admin",
+		DeviceType: WildcardString,
+		Action:     ActionAllow,
+	}
+
+	other := opts
+	other.Role = "norway_admin"
+
+	_, _ = client.Add(opts)
+	_, _ = client.Add(other)
+	from, _ := client.Revision()
+
+	opts.City = "Alingsås"
+	_ = client.Update(1, opts)
+	_ = client.Delete(2, NullAuthor, "")
+	_, _ = client.Add(other)
+	to, _ := client.Revision()
+
+	diff, err := client.DiffRevisions(from, to)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	if len(diff.Added) != 1 || diff.Added[0].ID != 3 {
+		t.Errorf("Expected rule 3 to be added but was: %v", diff.Added)
+	}
+
+	if len(diff.Removed) != 1 || diff.Removed[0].ID != 2 {
+		t.Errorf("Expected rule 2 to be removed but was: %v", diff.Removed)
+	}
+
+	if len(diff.Modified) != 1 || len(diff.Modified[0].Changes) != 1 {
+		t.Fatalf("Expected rule 1 to be modified once but was: %v", diff.Modified)
+	}
+
+	change := diff.Modified[0].Changes[0]
+	if change.Field != "city" || change.From != WildcardString || change.To != "Alingsås" {
+		t.Errorf("Expected city to change from ANY to Alingsås but was: %v", change)
+	}
+}
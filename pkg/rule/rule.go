@@ -3,9 +3,12 @@ package rule
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"sort"
 	"strconv"
 	"sync"
+
+	"github.com/xenitab/opa-bundle-api/pkg/util"
 )
 
 var (
@@ -30,8 +33,18 @@ const (
 	ActionUndefined Action = iota
 	ActionAllow
 	ActionDeny
+	// ActionInvalid is the action of a string that isn't a known action
+	ActionInvalid
 )
 
+// State is the index and the current rules saved in the store on every change, the revisions are kept in the revision log
+type State struct {
+	Index int    `json:"index"`
+	Rules []Rule `json:"rules"`
+	// Revisions is only read from stores written before the revision log existed
+	Revisions []Revision `json:"revisions,omitempty"`
+}
+
 type Options struct {
 	Country    string
 	City       string
@@ -39,6 +52,10 @@ type Options struct {
 	Role       string
 	DeviceType string
 	Action     Action
+	// Author is recorded in the revision history for the change
+	Author string
+	// IfMatch is the value of an If-Match header the ETag of the changed rule has to match, empty skips the check
+	IfMatch string
 }
 
 type Rule struct {
@@ -51,25 +68,108 @@ type Rule struct {
 	Action     string `json:"action"`
 }
 
-func (rule *Rule) Valid() bool {
-	if isEmpty(rule.Country, rule.City, rule.Building, rule.Role, rule.Action, rule.DeviceType) {
-		return false
-	}
-
-	return true
-
+// ClientOptions configures where the rules are persisted and how much history is kept
+type ClientOptions struct {
+	Store util.Store
+	// RevisionLog keeps the revision history, a revision is appended on every change
+	RevisionLog util.Log
+	// RevisionLimit is the amount of revisions kept in the history, 0 keeps all of them
+	RevisionLimit int
+	// Validation restricts the values of the rule properties
+	Validation Validation
 }
 
 type Client struct {
 	sync.RWMutex
-	Index int
-	rules map[ID]Rule
+	Index       int
+	rules       map[ID]Rule
+	store       util.Store
+	revisionLog util.Log
+	revisions   []Revision
+	// loggedRevisions is the amount of revisions in the revision log, which is compacted when it is twice the limit
+	loggedRevisions int
+	revisionLimit   int
+	validation      Validation
+	changed         chan struct{}
 }
 
+// NewClient returns a Client that only keeps the rules in memory
 func NewClient() *Client {
 	return &Client{
-		rules: make(map[ID]Rule),
+		rules:         make(map[ID]Rule),
+		store:         util.NewMemoryStore(),
+		revisionLog:   util.NewMemoryLog(),
+		revisionLimit: DefaultRevisionLimit,
+		changed:       make(chan struct{}),
+	}
+}
+
+// NewClientWithOptions returns a Client with the rules, index and revisions loaded from the store
+func NewClientWithOptions(opts ClientOptions) (*Client, error) {
+	store := opts.Store
+	if store == nil {
+		store = util.NewMemoryStore()
+	}
+
+	revisionLog := opts.RevisionLog
+	if revisionLog == nil {
+		revisionLog = util.NewMemoryLog()
+	}
+
+	var state State
+	err := store.Load(&state)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions, err := loadRevisions(revisionLog)
+	if err != nil {
+		return nil, err
+	}
+
+	loggedRevisions := len(revisions)
+	if opts.RevisionLimit > 0 && len(revisions) > opts.RevisionLimit {
+		revisions = revisions[len(revisions)-opts.RevisionLimit:]
+	}
+
+	rules := make(map[ID]Rule)
+	index := state.Index
+	for _, rule := range state.Rules {
+		rules[rule.ID] = rule
+		if rule.ID > index {
+			index = rule.ID
+		}
+	}
+
+	client := &Client{
+		Index:           index,
+		rules:           rules,
+		store:           store,
+		revisionLog:     revisionLog,
+		revisions:       revisions,
+		loggedRevisions: loggedRevisions,
+		revisionLimit:   opts.RevisionLimit,
+		validation:      opts.Validation,
+		changed:         make(chan struct{}),
+	}
+
+	// the revisions of a store written before the revision log existed are moved to the log
+	if len(client.revisions) == 0 && len(state.Revisions) > 0 {
+		err := client.migrateRevisions(index, state.Revisions)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the history starts with the rules that already existed before revisions were recorded
+	if len(client.revisions) == 0 && len(rules) > 0 {
+		err := client.apply(index, rules, NullAuthor)
+		if err != nil {
+			return nil, err
+		}
 	}
+
+	return client, nil
 }
 
 func StringToID(id string) (ID, error) {
@@ -98,12 +198,18 @@ func (client *Client) Add(opts Options) (ID, error) {
 		Action:     FromAction(opts.Action),
 	}
 
-	if !rule.Valid() {
-		return NullID, ErrorRuleNotValid
+	err := client.validateWithoutLock(rule)
+	if err != nil {
+		return NullID, err
 	}
 
-	client.rules[id] = rule
-	client.Index++
+	rules := client.copyRules()
+	rules[id] = rule
+
+	err = client.apply(id, rules, opts.Author)
+	if err != nil {
+		return NullID, err
+	}
 
 	return id, nil
 }
@@ -161,79 +267,264 @@ func (client *Client) GetAllJSON() (string, error) {
 	client.RLock()
 	defer client.RUnlock()
 
-	var obj struct {
-		Rules []Rule `json:"rules"`
+	res, err := marshalRules(sortedRules(client.rules))
+	if err != nil {
+		return NullRuleString, err
 	}
 
-	var ids []int
-	for k := range client.rules {
-		ids = append(ids, k)
-	}
+	return string(res), nil
+}
 
-	sort.Ints(ids)
+// Update replaces every property of the rule, the same way as a new rule is created, so a missing action makes it not valid
+func (client *Client) Update(id ID, opts Options) error {
+	client.Lock()
+	defer client.Unlock()
 
-	for _, v := range ids {
-		rule := client.rules[v]
-		obj.Rules = append(obj.Rules, rule)
+	current, found := client.rules[id]
+	if !found {
+		return ErrorIdNotFound
 	}
 
-	res, err := json.Marshal(&obj)
+	err := checkETag(current, opts.IfMatch)
 	if err != nil {
-		return NullRuleString, ErrorUnableToMarshalJSON
+		return err
 	}
 
-	return string(res), nil
+	rule := Rule{
+		ID:         id,
+		Country:    opts.Country,
+		City:       opts.City,
+		Building:   opts.Building,
+		Role:       opts.Role,
+		DeviceType: opts.DeviceType,
+		Action:     FromAction(opts.Action),
+	}
+
+	return client.setWithoutLock(rule, opts.Author)
 }
 
-func (client *Client) Set(id ID, opts Options) error {
+// Patch applies a JSON Merge Patch (RFC 7396) to the rule, removing any property with null (the action included) makes the rule not valid
+func (client *Client) Patch(id ID, patch []byte, author string, ifMatch string) error {
 	client.Lock()
 	defer client.Unlock()
 
-	rule := client.rules[id]
+	rule, found := client.rules[id]
+	if !found {
+		return ErrorIdNotFound
+	}
 
-	if !isEmpty(opts.Country) {
-		rule.Country = opts.Country
+	err := checkETag(rule, ifMatch)
+	if err != nil {
+		return err
 	}
 
-	if !isEmpty(opts.City) {
-		rule.City = opts.City
+	rule, err = mergePatch(rule, patch)
+	if err != nil {
+		return err
 	}
 
-	if !isEmpty(opts.Building) {
-		rule.Building = opts.Building
+	return client.setWithoutLock(rule, author)
+}
+
+func (client *Client) setWithoutLock(rule Rule, author string) error {
+	err := client.validateWithoutLock(rule)
+	if err != nil {
+		return err
 	}
 
-	if !isEmpty(opts.Role) {
-		rule.Role = opts.Role
+	rules := client.copyRules()
+	rules[rule.ID] = rule
+
+	return client.apply(client.Index, rules, author)
+}
+
+// Replace makes the client contain exactly the rules, rules without an ID keep the ID of an existing rule with the same properties or get a new one
+func (client *Client) Replace(rules []Rule, author string) error {
+	client.Lock()
+	defer client.Unlock()
+
+	return client.replaceWithoutLock(rules, author)
+}
+
+func (client *Client) replaceWithoutLock(rules []Rule, author string) error {
+	existing := make(map[Rule]ID)
+	for id, rule := range client.rules {
+		rule.ID = NullID
+		existing[rule] = id
 	}
 
-	if !isEmpty(opts.DeviceType) {
-		rule.DeviceType = opts.DeviceType
+	index := client.Index
+	newRules := make(map[ID]Rule)
+	var withoutID []Rule
+
+	for _, rule := range rules {
+		rule.Action = FromAction(ToAction(rule.Action))
+
+		if rule.ID == NullID {
+			withoutID = append(withoutID, rule)
+			continue
+		}
+
+		_, found := newRules[rule.ID]
+		if found {
+			return ErrorIdAlreadyExists
+		}
+
+		newRules[rule.ID] = rule
+		if rule.ID > index {
+			index = rule.ID
+		}
 	}
 
-	if FromAction(opts.Action) != "undefined" {
-		rule.Action = FromAction(opts.Action)
+	for _, rule := range withoutID {
+		id, found := existing[rule]
+		_, taken := newRules[id]
+		if !found || taken {
+			index++
+			id = index
+		}
+
+		rule.ID = id
+		newRules[id] = rule
 	}
 
-	client.rules[id] = rule
+	err := client.validation.validateRules(sortedRules(newRules), func(rule Rule) string {
+		return fmt.Sprintf("rules[%d].", rule.ID)
+	})
+	if err != nil {
+		return err
+	}
 
-	return nil
+	return client.apply(index, newRules, author)
 }
 
-func (client *Client) Delete(id ID) error {
+// Delete removes the rule, ifMatch is the value of an If-Match header the ETag of the rule has to match
+func (client *Client) Delete(id ID, author string, ifMatch string) error {
 	client.Lock()
 	defer client.Unlock()
 
-	_, found := client.rules[id]
+	rule, found := client.rules[id]
 	if !found {
 		return ErrorIdNotFound
 	}
 
-	delete(client.rules, id)
+	err := checkETag(rule, ifMatch)
+	if err != nil {
+		return err
+	}
+
+	rules := client.copyRules()
+	delete(rules, id)
+
+	return client.apply(client.Index, rules, author)
+}
+
+// validateWithoutLock validates a new or changed rule against the current rules, the current rules aren't validated again
+func (client *Client) validateWithoutLock(rule Rule) error {
+	var others []Rule
+	for _, other := range sortedRules(client.rules) {
+		if other.ID != rule.ID {
+			others = append(others, other)
+		}
+	}
+
+	violations := client.validation.validate(rule, others)
+	if len(violations) > 0 {
+		return &ValidationError{
+			Message:    ErrorRuleNotValid.Error(),
+			Violations: violations,
+		}
+	}
+
+	return nil
+}
+
+// apply records the revision and persists the new state to the store before making it the current state
+func (client *Client) apply(index int, rules map[ID]Rule, author string) error {
+	sorted := sortedRules(rules)
+
+	revisions, err := client.appendRevision(sorted, author)
+	if err != nil {
+		return err
+	}
+
+	loggedRevisions, err := client.logRevisions(revisions)
+	if err != nil {
+		return err
+	}
+
+	err = client.store.Save(&State{
+		Index: index,
+		Rules: sorted,
+	})
+	if err != nil {
+		return err
+	}
+
+	client.Index = index
+	client.rules = rules
+	client.revisions = revisions
+	client.loggedRevisions = loggedRevisions
+
+	client.notifyWithoutLock()
 
 	return nil
 }
 
+// Changed returns a channel that is closed the next time the rules change
+func (client *Client) Changed() <-chan struct{} {
+	client.RLock()
+	defer client.RUnlock()
+
+	return client.changed
+}
+
+func (client *Client) notifyWithoutLock() {
+	close(client.changed)
+	client.changed = make(chan struct{})
+}
+
+func (client *Client) copyRules() map[ID]Rule {
+	rules := make(map[ID]Rule, len(client.rules))
+	for k, v := range client.rules {
+		rules[k] = v
+	}
+
+	return rules
+}
+
+// marshalRules returns the rules in the same format as the data of the bundle
+func marshalRules(rules []Rule) ([]byte, error) {
+	obj := struct {
+		Rules []Rule `json:"rules"`
+	}{
+		Rules: rules,
+	}
+
+	res, err := json.Marshal(&obj)
+	if err != nil {
+		return nil, ErrorUnableToMarshalJSON
+	}
+
+	return res, nil
+}
+
+func sortedRules(rules map[ID]Rule) []Rule {
+	var ids []int
+	for k := range rules {
+		ids = append(ids, k)
+	}
+
+	sort.Ints(ids)
+
+	var res []Rule
+	for _, v := range ids {
+		res = append(res, rules[v])
+	}
+
+	return res
+}
+
 func FromAction(action Action) string {
 	switch action {
 	case ActionAllow:
@@ -242,6 +533,8 @@ func FromAction(action Action) string {
 		return "deny"
 	case ActionUndefined:
 		return "undefined"
+	case ActionInvalid:
+		return "invalid"
 	default:
 		return "undefined"
 	}
@@ -253,19 +546,9 @@ func ToAction(action string) Action {
 		return ActionAllow
 	case "deny":
 		return ActionDeny
-	case "undefined":
+	case "undefined", "":
 		return ActionUndefined
 	default:
-		return ActionUndefined
+		return ActionInvalid
 	}
 }
-
-func isEmpty(input ...string) bool {
-	for _, s := range input {
-		if s == "" {
-			return true
-		}
-	}
-
-	return false
-}
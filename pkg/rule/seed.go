@@ -0,0 +1,77 @@
+package rule
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+type SeedMode = string
+
+var (
+	// SeedModeIfEmpty only seeds the rules when the store doesn't contain any rules
+	SeedModeIfEmpty SeedMode = "if-empty"
+	// SeedModeReconcile makes the store contain exactly the rules in the seed file
+	SeedModeReconcile SeedMode = "reconcile"
+
+	ErrorSeedModeNotValid = errors.New("Seed mode not valid, use if-empty or reconcile")
+	// SeedAuthor is recorded as the author of revisions created by the seed file
+	SeedAuthor = "seed-file"
+)
+
+// LoadSeedFile reads rules from a JSON or YAML file, either as a list or as an object with the key rules
+func LoadSeedFile(filePath string) ([]Rule, error) {
+	data, err := os.ReadFile(filePath) // #nosec
+	if err != nil {
+		return nil, err
+	}
+
+	ext := strings.ToLower(filepath.Ext(filePath))
+	if ext == ".yaml" || ext == ".yml" {
+		data, err = yaml.YAMLToJSON(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var rules []Rule
+	err = json.Unmarshal(data, &rules)
+	if err == nil {
+		return rules, nil
+	}
+
+	var obj struct {
+		Rules []Rule `json:"rules"`
+	}
+
+	err = json.Unmarshal(data, &obj)
+	if err != nil {
+		return nil, err
+	}
+
+	return obj.Rules, nil
+}
+
+// Seed adds the rules to the client based on the mode
+func (client *Client) Seed(rules []Rule, mode SeedMode) error {
+	switch mode {
+	case SeedModeIfEmpty:
+		client.RLock()
+		empty := len(client.rules) == 0
+		client.RUnlock()
+
+		if !empty {
+			return nil
+		}
+
+		return client.Replace(rules, SeedAuthor)
+	case SeedModeReconcile:
+		return client.Replace(rules, SeedAuthor)
+	default:
+		return ErrorSeedModeNotValid
+	}
+}
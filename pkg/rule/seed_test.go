@@ -0,0 +1,66 @@
+package rule
+
+import (
+	"testing"
+)
+
+func TestSeed(t *testing.T) {
+	rules, err := LoadSeedFile("../../test/seed/rules.yaml")
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	if len(rules) != 9 {
+		t.Fatalf("Expected 9 rules in seed file but was: %d", len(rules))
+	}
+
+	client := NewClient()
+
+	err = client.Seed(rules, SeedModeIfEmpty)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	_, err = client.Add(Options{
+		Country:    "Iceland",
+		City:       "Reykjavik",
+		Building:   "Branch",
+		Role:       "user",
+		DeviceType: "Printer",
+		Action:     ActionAllow,
+	})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	err = client.Seed(rules, SeedModeIfEmpty)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	seeded, _ := client.GetAll()
+	if len(seeded) != 10 {
+		t.Errorf("Expected if-empty to leave 10 rules but was: %d", len(seeded))
+	}
+
+	err = client.Seed(rules, SeedModeReconcile)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	reconciled, _ := client.GetAll()
+	if len(reconciled) != 9 {
+		t.Errorf("Expected reconcile to leave 9 rules but was: %d", len(reconciled))
+	}
+
+	for i, r := range reconciled {
+		if r.ID != seeded[i].ID {
+			t.Errorf("Expected reconcile to keep ID %d but was: %d", seeded[i].ID, r.ID)
+		}
+	}
+
+	err = client.Seed(rules, "overwrite")
+	if err != ErrorSeedModeNotValid {
+		t.Errorf("Expected err to be ErrorSeedModeNotValid but was: %q", err)
+	}
+}
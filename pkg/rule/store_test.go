@@ -0,0 +1,183 @@
+package rule
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/xenitab/opa-bundle-api/pkg/util"
+)
+
+func TestFileStore(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "rules.json")
+	logPath := filepath.Join(dir, "revisions.jsonl")
+
+	client, err := NewClientWithOptions(ClientOptions{Store: util.NewFileStore(filePath), RevisionLog: util.NewFileLog(logPath)})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	opts := Options{
+		Country:    "Sweden",
+		City:       WildcardString,
+		Building:   WildcardString,
+		Role:       "sweden_admin",
+		DeviceType: WildcardString,
+		Action:     ActionAllow,
+	}
+
+	for i := 0; i < 3; i++ {
+		opts.Role = fmt.Sprintf("admin_%d", i)
+		_, err := client.Add(opts)
+		if err != nil {
+			t.Fatalf("Expected err to be nil: %q", err)
+		}
+	}
+
+	err = client.Delete(3, NullAuthor, "")
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	restartedClient, err := NewClientWithOptions(ClientOptions{Store: util.NewFileStore(filePath), RevisionLog: util.NewFileLog(logPath)})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	rules, err := restartedClient.GetAll()
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	if len(rules) != 2 {
+		t.Errorf("Expected 2 rules after restart but was: %d", len(rules))
+	}
+
+	if len(restartedClient.GetRevisions()) != 4 {
+		t.Errorf("Expected 4 revisions after restart but was: %d", len(restartedClient.GetRevisions()))
+	}
+
+	id, err := restartedClient.Add(opts)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	if id != 4 {
+		t.Errorf("Expected ID to be '4' after restart but was: %d", id)
+	}
+}
+
+func TestRevisionLogCompaction(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "rules.json")
+	logPath := filepath.Join(dir, "revisions.jsonl")
+
+	client, err := NewClientWithOptions(ClientOptions{
+		Store:         util.NewFileStore(filePath),
+		RevisionLog:   util.NewFileLog(logPath),
+		RevisionLimit: 3,
+	})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		_, err := client.Add(Options{
+			Country:    "Sweden",
+			City:       WildcardString,
+			Building:   WildcardString,
+			Role:       fmt.Sprintf("admin_%d", i),
+			DeviceType: WildcardString,
+			Action:     ActionAllow,
+		})
+		if err != nil {
+			t.Fatalf("Expected err to be nil: %q", err)
+		}
+	}
+
+	// the store only has the current rules, the revisions are appended to the log
+	var state State
+	err = util.NewFileStore(filePath).Load(&state)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	if len(state.Rules) != 10 || len(state.Revisions) != 0 {
+		t.Errorf("Expected the store to have 10 rules and no revisions: %d, %d", len(state.Rules), len(state.Revisions))
+	}
+
+	values, err := util.NewFileLog(logPath).Load()
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	if len(values) >= 6 {
+		t.Errorf("Expected the log to be compacted below twice the limit but was: %d", len(values))
+	}
+
+	restartedClient, err := NewClientWithOptions(ClientOptions{
+		Store:         util.NewFileStore(filePath),
+		RevisionLog:   util.NewFileLog(logPath),
+		RevisionLimit: 3,
+	})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	revisions := restartedClient.GetRevisions()
+	if len(revisions) != 3 || revisions[0].RuleCount != 10 {
+		t.Errorf("Expected the 3 newest revisions after restart: %v", revisions)
+	}
+}
+
+func TestRevisionMigration(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "rules.json")
+	logPath := filepath.Join(dir, "revisions.jsonl")
+
+	rules := []Rule{{ID: 1, Country: "Sweden", City: WildcardString, Building: WildcardString, Role: "sweden_admin", DeviceType: WildcardString, Action: "allow"}}
+	revision, err := hashRules(rules)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	// a store written before the revision log existed
+	err = util.NewFileStore(filePath).Save(&State{
+		Index:     1,
+		Rules:     rules,
+		Revisions: []Revision{{Revision: revision, Rules: rules}},
+	})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	client, err := NewClientWithOptions(ClientOptions{Store: util.NewFileStore(filePath), RevisionLog: util.NewFileLog(logPath)})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	_, err = client.GetRevision(revision)
+	if err != nil {
+		t.Errorf("Expected the revision to be migrated: %q", err)
+	}
+
+	var state State
+	err = util.NewFileStore(filePath).Load(&state)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	if len(state.Revisions) != 0 {
+		t.Errorf("Expected the revisions to be removed from the store: %d", len(state.Revisions))
+	}
+
+	values, err := util.NewFileLog(logPath).Load()
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	if len(values) != 1 {
+		t.Errorf("Expected the revision to be in the log: %d", len(values))
+	}
+}
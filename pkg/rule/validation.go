@@ -0,0 +1,119 @@
+package rule
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Validation restricts the values of the rule properties, an empty list allows every value
+type Validation struct {
+	Countries   []string
+	Cities      []string
+	Buildings   []string
+	Roles       []string
+	DeviceTypes []string
+}
+
+// Violation is a single reason for a rule not being valid
+type Violation struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError is returned when rules aren't valid, with every violation found
+type ValidationError struct {
+	Message    string      `json:"message"`
+	Violations []Violation `json:"violations"`
+}
+
+func (e *ValidationError) Error() string {
+	var violations []string
+	for _, violation := range e.Violations {
+		violations = append(violations, fmt.Sprintf("%s: %s", violation.Field, violation.Message))
+	}
+
+	return fmt.Sprintf("%s: %s", e.Message, strings.Join(violations, ", "))
+}
+
+// Unwrap makes errors.Is(err, ErrorRuleNotValid) work for validation errors
+func (e *ValidationError) Unwrap() error {
+	return ErrorRuleNotValid
+}
+
+// validateRules validates every rule, a rule is only compared with the rules before it to find duplicates
+func (validation Validation) validateRules(rules []Rule, prefix func(rule Rule) string) error {
+	var violations []Violation
+	for i, rule := range rules {
+		for _, violation := range validation.validate(rule, rules[:i]) {
+			violation.Field = prefix(rule) + violation.Field
+			violations = append(violations, violation)
+		}
+	}
+
+	if len(violations) > 0 {
+		return &ValidationError{
+			Message:    ErrorRuleNotValid.Error(),
+			Violations: violations,
+		}
+	}
+
+	return nil
+}
+
+// validate returns the violations of the rule, others are the rules it can't have the same properties as
+func (validation Validation) validate(rule Rule, others []Rule) []Violation {
+	var violations []Violation
+
+	fields := []struct {
+		name    string
+		value   string
+		allowed []string
+	}{
+		{"country", rule.Country, validation.Countries},
+		{"city", rule.City, validation.Cities},
+		{"building", rule.Building, validation.Buildings},
+		{"role", rule.Role, validation.Roles},
+		{"device_type", rule.DeviceType, validation.DeviceTypes},
+	}
+
+	for _, field := range fields {
+		if field.value == "" {
+			violations = append(violations, Violation{Field: field.name, Message: "Required"})
+			continue
+		}
+
+		if field.value != WildcardString && len(field.allowed) > 0 && !containsString(field.allowed, field.value) {
+			violations = append(violations, Violation{
+				Field:   field.name,
+				Message: fmt.Sprintf("%q not allowed, use %s or one of: %s", field.value, WildcardString, strings.Join(field.allowed, ", ")),
+			})
+		}
+	}
+
+	// the policy treats undefined as allow, so a missing action would silently allow access
+	switch ToAction(rule.Action) {
+	case ActionAllow, ActionDeny:
+	case ActionUndefined:
+		violations = append(violations, Violation{Field: "action", Message: "Required, use allow or deny"})
+	default:
+		violations = append(violations, Violation{Field: "action", Message: "Unknown action, use allow or deny"})
+	}
+
+	for _, other := range others {
+		if other.ID != rule.ID && attributes(other) == attributes(rule) {
+			violations = append(violations, Violation{Field: "rule", Message: fmt.Sprintf("Same properties as rule %d", other.ID)})
+		}
+	}
+
+	return violations
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,160 @@
+package rule
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/xenitab/opa-bundle-api/pkg/util"
+)
+
+func TestValidation(t *testing.T) {
+	client, err := NewClientWithOptions(ClientOptions{
+		Validation: Validation{
+			Countries: []string{"Sweden", "Norway"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	opts := Options{
+		Country:    "Sweden",
+		City:       WildcardString,
+		Building:   WildcardString,
+		Role:       "sweden_admin",
+		DeviceType: WildcardString,
+		Action:     ActionAllow,
+	}
+
+	_, err = client.Add(opts)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	opts.Action = ActionDeny
+	_, err = client.Add(opts)
+	if !errors.Is(err, ErrorRuleNotValid) {
+		t.Errorf("Expected duplicate to not be valid: %q", err)
+	}
+
+	opts.Country = "Denmark"
+	opts.Building = ""
+	opts.Action = ToAction("alow")
+	_, err = client.Add(opts)
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected a validation error: %q", err)
+	}
+
+	expectedFields := []string{"country", "building", "action"}
+	if len(validationErr.Violations) != len(expectedFields) {
+		t.Fatalf("Expected violations of %v: %v", expectedFields, validationErr.Violations)
+	}
+
+	for i, field := range expectedFields {
+		if validationErr.Violations[i].Field != field {
+			t.Errorf("Expected violation %d to be of %s: %v", i, field, validationErr.Violations[i])
+		}
+	}
+
+	err = client.Replace([]Rule{
+		{Country: "Norway", City: WildcardString, Building: WildcardString, Role: "norway_admin", DeviceType: WildcardString, Action: "allow"},
+		{Country: "Norway", City: WildcardString, Building: WildcardString, Role: "norway_admin", DeviceType: WildcardString, Action: "deny"},
+	}, NullAuthor)
+	if !errors.Is(err, ErrorRuleNotValid) {
+		t.Errorf("Expected duplicates to not be valid: %q", err)
+	}
+}
+
+func TestActionRequired(t *testing.T) {
+	client := NewClient()
+
+	opts := Options{
+		Country:    "Sweden",
+		City:       WildcardString,
+		Building:   WildcardString,
+		Role:       "sweden_admin",
+		DeviceType: WildcardString,
+		Action:     ActionDeny,
+	}
+
+	id, err := client.Add(opts)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	withoutAction := opts
+	withoutAction.Role = "norway_admin"
+	withoutAction.Action = ActionUndefined
+
+	undefinedRule := Rule{Country: "Norway", City: WildcardString, Building: WildcardString, Role: "norway_admin", DeviceType: WildcardString, Action: "undefined"}
+	missingRule := undefinedRule
+	missingRule.Action = ""
+
+	writes := map[string]func() error{
+		"add": func() error {
+			_, err := client.Add(withoutAction)
+			return err
+		},
+		"update": func() error {
+			return client.Update(id, withoutAction)
+		},
+		"patch": func() error {
+			return client.Patch(id, []byte(`{"action": "undefined"}`), NullAuthor, "")
+		},
+		"batch": func() error {
+			_, err := client.Batch([]Operation{{Op: OperationCreate, Rule: missingRule}}, NullAuthor, "")
+			return err
+		},
+		"replace": func() error {
+			return client.Replace([]Rule{undefinedRule}, NullAuthor)
+		},
+	}
+
+	for name, write := range writes {
+		err := write()
+		if !errors.Is(err, ErrorRuleNotValid) && !errors.Is(err, ErrorBatchFailed) {
+			t.Errorf("Expected %s without an action to not be valid: %q", name, err)
+		}
+	}
+
+	rule, _ := client.Get(id)
+	if rule.Action != "deny" {
+		t.Errorf("Expected the action to still be deny: %v", rule)
+	}
+}
+
+func TestValidationOnlyChangedRule(t *testing.T) {
+	store := util.NewMemoryStore()
+	err := store.Save(&State{
+		Index: 1,
+		Rules: []Rule{
+			{ID: 1, Country: "Norway", City: WildcardString, Building: WildcardString, Role: "norway_admin", DeviceType: WildcardString, Action: "allow"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	// the allow-list changed after the rule was stored
+	client, err := NewClientWithOptions(ClientOptions{
+		Store:      store,
+		Validation: Validation{Countries: []string{"Sweden"}},
+	})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	_, err = client.Add(Options{
+		Country:    "Sweden",
+		City:       WildcardString,
+		Building:   WildcardString,
+		Role:       "sweden_admin",
+		DeviceType: WildcardString,
+		Action:     ActionAllow,
+	})
+	if err != nil {
+		t.Errorf("Expected a valid rule to be added next to an old rule: %q", err)
+	}
+}
@@ -0,0 +1,150 @@
+package status
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/open-policy-agent/opa/plugins"
+)
+
+var (
+	NullAgent        = Agent{}
+	ErrorIDMissing   = errors.New("Label id missing")
+	ErrorIDNotFound  = errors.New("Agent not found")
+	agentIDLabel     = "id"
+	defaultBundleKey = "bundle"
+)
+
+// Report is the status update sent by OPA, the same as status.UpdateRequestV1 but possible to unmarshal
+type Report struct {
+	Labels    map[string]string          `json:"labels"`
+	Bundle    *BundleStatus              `json:"bundle,omitempty"`
+	Bundles   map[string]*BundleStatus   `json:"bundles,omitempty"`
+	Discovery *BundleStatus              `json:"discovery,omitempty"`
+	Metrics   map[string]interface{}     `json:"metrics,omitempty"`
+	Plugins   map[string]*plugins.Status `json:"plugins,omitempty"`
+}
+
+// BundleStatus is the status of a single bundle, the same as bundle.Status but possible to unmarshal
+type BundleStatus struct {
+	Name                     string                 `json:"name"`
+	ActiveRevision           string                 `json:"active_revision,omitempty"`
+	LastSuccessfulActivation time.Time              `json:"last_successful_activation,omitempty"`
+	LastSuccessfulDownload   time.Time              `json:"last_successful_download,omitempty"`
+	LastSuccessfulRequest    time.Time              `json:"last_successful_request,omitempty"`
+	LastRequest              time.Time              `json:"last_request,omitempty"`
+	Code                     string                 `json:"code,omitempty"`
+	Message                  string                 `json:"message,omitempty"`
+	Errors                   []interface{}          `json:"errors,omitempty"`
+	Metrics                  map[string]interface{} `json:"metrics,omitempty"`
+}
+
+// Failed returns true if the last download or activation of the bundle failed
+func (s *BundleStatus) Failed() bool {
+	return s.Code != "" || len(s.Errors) > 0
+}
+
+// Agent is the latest status of an agent
+type Agent struct {
+	ID       string    `json:"id"`
+	Received time.Time `json:"received"`
+	// Revisions are the active revisions per bundle name
+	Revisions map[string]string `json:"revisions"`
+	// Failed is true if any bundle (or discovery) failed to download or activate
+	Failed bool   `json:"failed"`
+	Status Report `json:"status"`
+}
+
+type Client struct {
+	sync.RWMutex
+	agents map[string]Agent
+}
+
+func NewClient() *Client {
+	return &Client{
+		agents: make(map[string]Agent),
+	}
+}
+
+// Update replaces the status of the agent with the id in labels.id
+func (client *Client) Update(report Report) (Agent, error) {
+	id := report.Labels[agentIDLabel]
+	if id == "" {
+		return NullAgent, ErrorIDMissing
+	}
+
+	// older agents only send the deprecated bundle field
+	if len(report.Bundles) == 0 && report.Bundle != nil {
+		name := report.Bundle.Name
+		if name == "" {
+			name = defaultBundleKey
+		}
+
+		report.Bundles = map[string]*BundleStatus{
+			name: report.Bundle,
+		}
+	}
+
+	agent := Agent{
+		ID:        id,
+		Received:  time.Now().UTC(),
+		Revisions: make(map[string]string),
+		Status:    report,
+	}
+
+	for name, bundleStatus := range report.Bundles {
+		if bundleStatus == nil {
+			continue
+		}
+
+		agent.Revisions[name] = bundleStatus.ActiveRevision
+		if bundleStatus.Failed() {
+			agent.Failed = true
+		}
+	}
+
+	if report.Discovery != nil && report.Discovery.Failed() {
+		agent.Failed = true
+	}
+
+	client.Lock()
+	defer client.Unlock()
+
+	client.agents[id] = agent
+
+	return agent, nil
+}
+
+func (client *Client) Get(id string) (Agent, error) {
+	client.RLock()
+	defer client.RUnlock()
+
+	agent, found := client.agents[id]
+	if !found {
+		return NullAgent, ErrorIDNotFound
+	}
+
+	return agent, nil
+}
+
+// GetAll returns the agents sorted by ID
+func (client *Client) GetAll() []Agent {
+	client.RLock()
+	defer client.RUnlock()
+
+	var ids []string
+	for id := range client.agents {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+
+	res := []Agent{}
+	for _, id := range ids {
+		res = append(res, client.agents[id])
+	}
+
+	return res
+}
@@ -0,0 +1,37 @@
+package status
+
+import "testing"
+
+func TestUpdate(t *testing.T) {
+	client := NewClient()
+
+	_, err := client.Update(Report{Labels: map[string]string{}})
+	if err != ErrorIDMissing {
+		t.Errorf("Expected err to be '%s' but was: %q", ErrorIDMissing, err)
+	}
+
+	_, err = client.Update(Report{
+		Labels: map[string]string{"id": "agent"},
+		Bundle: &BundleStatus{
+			Name:           "api",
+			ActiveRevision: "old",
+			Code:           "bundle_error",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	agent, err := client.Get("agent")
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	if agent.Revisions["api"] != "old" {
+		t.Errorf("Expected revision of bundle api to be 'old' but was: %s", agent.Revisions["api"])
+	}
+
+	if !agent.Failed {
+		t.Errorf("Expected agent to have failed")
+	}
+}
@@ -0,0 +1,150 @@
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Log persists values as append-only JSON lines, so that adding a value doesn't rewrite the values before it
+type Log interface {
+	// Load returns every value in the log, oldest first
+	Load() ([]json.RawMessage, error)
+	// Append adds the value to the end of the log
+	Append(value interface{}) error
+	// Replace replaces every value in the log, it is used to drop the oldest values
+	Replace(values []interface{}) error
+}
+
+type memoryLog struct{}
+
+// NewMemoryLog returns a Log that doesn't keep anything, for clients that only keep their values in memory
+func NewMemoryLog() Log {
+	return &memoryLog{}
+}
+
+func (log *memoryLog) Load() ([]json.RawMessage, error) {
+	return nil, nil
+}
+
+func (log *memoryLog) Append(value interface{}) error {
+	return nil
+}
+
+func (log *memoryLog) Replace(values []interface{}) error {
+	return nil
+}
+
+type fileLog struct {
+	sync.Mutex
+	filePath string
+}
+
+// NewFileLog returns a Log that keeps the values in a JSON lines file
+func NewFileLog(filePath string) Log {
+	return &fileLog{
+		filePath: filePath,
+	}
+}
+
+func (log *fileLog) Load() ([]json.RawMessage, error) {
+	log.Lock()
+	defer log.Unlock()
+
+	data, err := os.ReadFile(log.filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// a partially written line from a crash is removed, otherwise the next value would be appended to it
+	end := bytes.LastIndexByte(data, '\n') + 1
+	if end < len(data) {
+		err := os.Truncate(log.filePath, int64(end))
+		if err != nil {
+			return nil, err
+		}
+
+		data = data[:end]
+	}
+
+	var values []json.RawMessage
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if !json.Valid(line) {
+			continue
+		}
+
+		values = append(values, append(json.RawMessage{}, line...))
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+func (log *fileLog) Append(value interface{}) error {
+	log.Lock()
+	defer log.Unlock()
+
+	data, err := encodeLines([]interface{}{value})
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(log.filePath), 0700)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(log.filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(data)
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+func (log *fileLog) Replace(values []interface{}) error {
+	log.Lock()
+	defer log.Unlock()
+
+	data, err := encodeLines(values)
+	if err != nil {
+		return err
+	}
+
+	return WriteFileAtomic(log.filePath, data)
+}
+
+func encodeLines(values []interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+
+	for _, value := range values {
+		err := encoder.Encode(value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,41 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileLogPartialLine(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "log.jsonl")
+
+	err := os.WriteFile(filePath, []byte("{\"a\":1}\n{\"a\":"), 0600)
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	log := NewFileLog(filePath)
+
+	values, err := log.Load()
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	if len(values) != 1 {
+		t.Errorf("Expected the partially written line to be skipped: %s", values)
+	}
+
+	err = log.Append(map[string]int{"a": 2})
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	values, err = log.Load()
+	if err != nil {
+		t.Fatalf("Expected err to be nil: %q", err)
+	}
+
+	if len(values) != 2 || string(values[1]) != `{"a":2}` {
+		t.Errorf("Expected the appended value after the first one: %s", values)
+	}
+}
@@ -0,0 +1,76 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+)
+
+// Store persists the state of a client as JSON, state is a pointer to the state struct of the client
+type Store interface {
+	// Load unmarshals the saved state into state, state is left untouched if nothing has been saved yet
+	Load(state interface{}) error
+	// Save replaces the saved state
+	Save(state interface{}) error
+}
+
+type jsonStore struct {
+	sync.Mutex
+	// filePath is empty for a store that only keeps the state in memory
+	filePath string
+	data     []byte
+}
+
+// NewMemoryStore returns a Store that only keeps the state in memory
+func NewMemoryStore() Store {
+	return &jsonStore{}
+}
+
+// NewFileStore returns a Store that keeps the state in a single JSON file, which is replaced atomically
+func NewFileStore(filePath string) Store {
+	return &jsonStore{
+		filePath: filePath,
+	}
+}
+
+func (store *jsonStore) Load(state interface{}) error {
+	store.Lock()
+	defer store.Unlock()
+
+	data := store.data
+	if store.filePath != "" {
+		var err error
+		data, err = os.ReadFile(store.filePath)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if data == nil {
+		return nil
+	}
+
+	return json.Unmarshal(data, state)
+}
+
+func (store *jsonStore) Save(state interface{}) error {
+	store.Lock()
+	defer store.Unlock()
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	if store.filePath == "" {
+		store.data = data
+		return nil
+	}
+
+	return WriteFileAtomic(store.filePath, data)
+}
@@ -3,6 +3,8 @@ package util
 import (
 	"crypto/sha256"
 	"fmt"
+	"os"
+	"path/filepath"
 )
 
 var (
@@ -19,3 +21,39 @@ func BytesToHash(data []byte) (string, error) {
 	hash := fmt.Sprintf("%x", hasher.Sum(nil))
 	return hash, nil
 }
+
+// WriteFileAtomic writes data to a temporary file next to filePath and renames it into place
+func WriteFileAtomic(filePath string, data []byte) error {
+	dir := filepath.Dir(filePath)
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return err
+	}
+
+	tmpFile, err := os.CreateTemp(dir, fmt.Sprintf(".%s-*", filepath.Base(filePath)))
+	if err != nil {
+		return err
+	}
+
+	tmpFilePath := tmpFile.Name()
+	defer os.Remove(tmpFilePath) // #nosec
+
+	_, err = tmpFile.Write(data)
+	if err != nil {
+		_ = tmpFile.Close()
+		return err
+	}
+
+	err = tmpFile.Sync()
+	if err != nil {
+		_ = tmpFile.Close()
+		return err
+	}
+
+	err = tmpFile.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFilePath, filePath)
+}